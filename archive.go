@@ -0,0 +1,469 @@
+package gora
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// ArchiveSeparator separates the path of an archive and the path of its member in the virtual paths of the archive
+// members, such as "/tmp/a.zip!/docs/x.js".
+const ArchiveSeparator = "!/"
+
+// The default archive scan limits. See ArchiveOptions.
+const (
+	DefaultArchiveMaxDepth            = 3
+	DefaultArchiveMaxTotalBytes int64 = 1 << 30
+	DefaultArchiveMaxRatio            = 100
+	DefaultArchiveSpoolSize     int64 = 32 << 20
+)
+
+// archiveRatioMinSize is the extracted size the compression ratio limit is applied after, not to reject the small
+// members of high compression ratios.
+const archiveRatioMinSize = 1 << 20
+
+// ErrArchiveLimit is wrapped by the errors of the archive members exceeding the archive scan limits.
+var ErrArchiveLimit = errors.New("archive limit exceeded")
+
+// ArchiveOptions holds the settings of ScanArchive. Zero values use the defaults.
+type ArchiveOptions struct {
+	// MaxDepth is the maximum nesting depth of the archives extracted. The archive scanned is at depth 1, and the
+	// archives deeper than it are scanned as plain files.
+	MaxDepth int
+	// MaxTotalBytes is the maximum number of bytes extracted from the archive scanned, including the nested ones.
+	// Extraction stops when it is exceeded.
+	MaxTotalBytes int64
+	// MaxRatio is the maximum compression ratio of the compressed members. Members exceeding it are skipped.
+	MaxRatio float64
+	// SpoolSize is the maximum size of the members extracted in memory. Larger members are spooled to temporary
+	// files to be scanned.
+	SpoolSize int64
+	// TempDir is the directory of the temporary files. os.TempDir is used if it is empty.
+	TempDir string
+}
+
+// ScanArchive defines the variables using the given scan context and scans the given file like ScanFile. Then, if it
+// is a zip, tar, gzip or tar.gz archive, it extracts and scans its members recursively. The members are scanned with
+// the virtual paths such as "/tmp/a.zip!/docs/x.js" as file_path, and the file infos synthesized from the archive
+// headers. See ArchiveOptions for the limits protecting against archive bombs.
+//
+// The given function is called after each scan to report the results, since the callback set by SetCallback does not
+// know the member scanned. It is also called with the errors of extracting the archives and the members, such as the
+// errors wrapping ErrArchiveLimit. If it is nil, ScanArchive stops at the first error and returns it.
+func (c *Compiled) ScanArchive(filePath string, sctx variables.ScanContext, opts ArchiveOptions,
+	fn ScanFunc) error {
+	if fn == nil {
+		fn = returnScanError
+	}
+	w := &archiveWalker{
+		c:    c,
+		sctx: sctx,
+		ctx:  sctx.Context(),
+		opts: opts.withDefaults(),
+		fn:   fn,
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fn(filePath, fileScanError(err, filePath))
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fn(filePath, fileScanError(err, filePath))
+	}
+
+	err = c.DefineScannerVariables(sctx)
+	if err == nil {
+		err = c.ScanFileContext(w.ctx, filePath)
+	}
+	if err = fn(filePath, err); err != nil {
+		return err
+	}
+	return w.walk(&archiveItem{path: filePath, info: info, file: f}, 1)
+}
+
+func (o ArchiveOptions) withDefaults() ArchiveOptions {
+	if o.MaxDepth <= 0 {
+		o.MaxDepth = DefaultArchiveMaxDepth
+	}
+	if o.MaxTotalBytes <= 0 {
+		o.MaxTotalBytes = DefaultArchiveMaxTotalBytes
+	}
+	if o.MaxRatio <= 0 {
+		o.MaxRatio = DefaultArchiveMaxRatio
+	}
+	if o.SpoolSize <= 0 {
+		o.SpoolSize = DefaultArchiveSpoolSize
+	}
+	return o
+}
+
+// archiveItem is a file or an archive member to be scanned. Its data is either in memory or in a file.
+type archiveItem struct {
+	path    string
+	info    fs.FileInfo
+	data    []byte
+	file    *os.File
+	spooled bool
+}
+
+func (it *archiveItem) size() int64 {
+	if it.file == nil {
+		return int64(len(it.data))
+	}
+	return it.info.Size()
+}
+
+func (it *archiveItem) readerAt() io.ReaderAt {
+	if it.file == nil {
+		return bytes.NewReader(it.data)
+	}
+	return it.file
+}
+
+func (it *archiveItem) reader() io.Reader {
+	return io.NewSectionReader(it.readerAt(), 0, it.size())
+}
+
+func (it *archiveItem) close() {
+	if it.spooled {
+		_ = it.file.Close()
+		_ = os.Remove(it.file.Name())
+	}
+}
+
+// archiveWalker extracts and scans the archive members recursively.
+type archiveWalker struct {
+	c     *Compiled
+	sctx  variables.ScanContext
+	ctx   context.Context
+	opts  ArchiveOptions
+	fn    ScanFunc
+	total int64
+	// stopped is set when the total bytes limit is exceeded to stop extracting.
+	stopped bool
+}
+
+// walk extracts and scans the members of the given item if it is an archive, and if the given depth is not deeper
+// than the maximum depth.
+func (w *archiveWalker) walk(it *archiveItem, depth int) error {
+	if depth > w.opts.MaxDepth || w.stopped {
+		return nil
+	}
+
+	header := make([]byte, 512)
+	n, err := it.readerAt().ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return w.fn(it.path, err)
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		err = w.walkZip(it, depth)
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		err = w.walkGzip(it, depth)
+	case isTarHeader(header):
+		err = w.walkTar(tar.NewReader(it.reader()), it.path, depth)
+	}
+	return err
+}
+
+func (w *archiveWalker) walkZip(it *archiveItem, depth int) error {
+	zr, err := zip.NewReader(it.readerAt(), it.size())
+	if err != nil {
+		return w.fn(it.path, fmt.Errorf("zip: %w", err))
+	}
+	for _, f := range zr.File {
+		if w.stopped {
+			return nil
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		memberPath := archiveMemberPath(it.path, f.Name)
+		if f.CompressedSize64 > 0 && f.UncompressedSize64 > archiveRatioMinSize &&
+			float64(f.UncompressedSize64) > float64(f.CompressedSize64)*w.opts.MaxRatio {
+			if err = w.fn(memberPath, w.ratioError()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			if err = w.fn(memberPath, fmt.Errorf("zip: %w", err)); err != nil {
+				return err
+			}
+			continue
+		}
+		info := &variables.VirtualFileInfo{
+			FileName:     path.Base(f.Name),
+			FileMode:     f.Mode(),
+			ModifiedTime: f.Modified,
+		}
+		err = w.member(rc, memberPath, info, int64(f.CompressedSize64), depth)
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *archiveWalker) walkTar(tr *tar.Reader, parent string, depth int) error {
+	for !w.stopped {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return w.fn(parent, fmt.Errorf("tar: %w", err))
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		info := &variables.VirtualFileInfo{
+			FileName:     path.Base(hdr.Name),
+			FileMode:     hdr.FileInfo().Mode(),
+			ModifiedTime: hdr.ModTime,
+			AccessedTime: hdr.AccessTime,
+			ChangedTime:  hdr.ChangeTime,
+		}
+		if err = w.member(tr, archiveMemberPath(parent, hdr.Name), info, -1, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkGzip extracts the gzip member of the given item. A compressed tar archive is walked as a tar archive with the
+// path of the gzip file, such as "/tmp/a.tar.gz!/docs/x.js".
+func (w *archiveWalker) walkGzip(it *archiveItem, depth int) error {
+	gr, err := gzip.NewReader(it.reader())
+	if err != nil {
+		return w.fn(it.path, fmt.Errorf("gzip: %w", err))
+	}
+	defer gr.Close()
+
+	rr := &ratioReader{r: gr, compressed: it.size(), ratio: w.opts.MaxRatio}
+	br := bufio.NewReaderSize(rr, 1024)
+	if header, _ := br.Peek(512); isTarHeader(header) {
+		return w.walkTar(tar.NewReader(br), it.path, depth)
+	}
+
+	name := gr.Name
+	if name == "" {
+		name = gzipMemberName(path.Base(strings.ReplaceAll(it.path, "\\", "/")))
+	}
+	info := &variables.VirtualFileInfo{
+		FileName:     path.Base(name),
+		FileMode:     0o644,
+		ModifiedTime: gr.ModTime,
+	}
+	return w.member(br, archiveMemberPath(it.path, name), info, -1, depth)
+}
+
+// member extracts the archive member read from the given reader, scans it and walks it if it is an archive. The
+// compressed size of the member is used to apply the compression ratio limit, if it is not negative.
+func (w *archiveWalker) member(r io.Reader, memberPath string, info *variables.VirtualFileInfo, compressed int64,
+	depth int) error {
+	if err := w.ctx.Err(); err != nil {
+		return &CancelledError{Err: err}
+	}
+	if compressed >= 0 {
+		r = &ratioReader{r: r, compressed: compressed, ratio: w.opts.MaxRatio}
+	}
+
+	it, err := w.extract(r, memberPath, info)
+	if err != nil {
+		return w.fn(memberPath, err)
+	}
+	defer it.close()
+
+	if err = w.fn(memberPath, w.scan(it)); err != nil {
+		return err
+	}
+	return w.walk(it, depth+1)
+}
+
+// extract reads the archive member in memory, or into a temporary file if it is larger than the spool size.
+func (w *archiveWalker) extract(r io.Reader, memberPath string, info *variables.VirtualFileInfo) (*archiveItem, error) {
+	remaining := w.opts.MaxTotalBytes - w.total
+	lr := &io.LimitedReader{R: r, N: remaining + 1}
+
+	buf := new(bytes.Buffer)
+	n, err := io.Copy(buf, io.LimitReader(lr, w.opts.SpoolSize+1))
+	if err != nil {
+		return nil, err
+	}
+	it := &archiveItem{path: memberPath, info: info}
+	if n <= w.opts.SpoolSize {
+		it.data = buf.Bytes()
+	} else {
+		f, err := os.CreateTemp(w.opts.TempDir, "gora-*")
+		if err != nil {
+			return nil, err
+		}
+		it.file, it.spooled = f, true
+		m, err := io.Copy(f, io.MultiReader(buf, lr))
+		if err != nil {
+			it.close()
+			return nil, err
+		}
+		n = m
+	}
+
+	w.total += n
+	if n > remaining {
+		it.close()
+		w.stopped = true
+		return nil, fmt.Errorf("%w: total extracted bytes exceed %d", ErrArchiveLimit, w.opts.MaxTotalBytes)
+	}
+	info.FileSize = n
+	return it, nil
+}
+
+// scan scans the extracted archive member.
+func (w *archiveWalker) scan(it *archiveItem) error {
+	msctx := &fileScanContext{ScanContext: w.sctx, path: it.path, info: it.info}
+	if !it.spooled {
+		return w.c.ScanMem(it.data, msctx)
+	}
+
+	if err := w.c.DefineScannerVariables(msctx); err != nil {
+		return err
+	}
+	err := w.c.scanFile(w.ctx, it.file.Name(), &evidenceSource{path: it.path, r: it.file})
+	var serr *ScanError
+	if errors.As(err, &serr) {
+		serr.Path = it.path
+	}
+	return err
+}
+
+func (w *archiveWalker) ratioError() error {
+	return fmt.Errorf("%w: compression ratio exceeds %g", ErrArchiveLimit, w.opts.MaxRatio)
+}
+
+// fileScanContext overrides the file path, the real path and the file info of a scan context. It is the scan context of the archive
+// members and the files scanned in the forensic mode.
+type fileScanContext struct {
+	variables.ScanContext
+	path     string
+	realPath string
+	info     fs.FileInfo
+}
+
+var (
+	_ variables.DefaultRecorder    = (*fileScanContext)(nil)
+	_ variables.RealPathProvider   = (*fileScanContext)(nil)
+	_ variables.PathMapper         = (*fileScanContext)(nil)
+	_ variables.MountTableProvider = (*fileScanContext)(nil)
+	_ variables.PackageDBProvider  = (*fileScanContext)(nil)
+)
+
+func (sc *fileScanContext) FilePath() string {
+	return sc.path
+}
+
+func (sc *fileScanContext) FileInfo() fs.FileInfo {
+	return sc.info
+}
+
+func (sc *fileScanContext) RealPath() string {
+	return sc.realPath
+}
+
+// PathMapping forwards to the overridden scan context if it implements variables.PathMapper.
+func (sc *fileScanContext) PathMapping() *variables.PathMapping {
+	if m, ok := sc.ScanContext.(variables.PathMapper); ok {
+		return m.PathMapping()
+	}
+	return nil
+}
+
+// MountTable forwards to the overridden scan context if it implements variables.MountTableProvider.
+func (sc *fileScanContext) MountTable() *variables.MountTable {
+	if p, ok := sc.ScanContext.(variables.MountTableProvider); ok {
+		return p.MountTable()
+	}
+	return nil
+}
+
+// PackageDB forwards to the overridden scan context if it implements variables.PackageDBProvider.
+func (sc *fileScanContext) PackageDB() *variables.PackageDB {
+	if p, ok := sc.ScanContext.(variables.PackageDBProvider); ok {
+		return p.PackageDB()
+	}
+	return nil
+}
+
+// RecordDefault forwards to the overridden scan context if it implements variables.DefaultRecorder.
+func (sc *fileScanContext) RecordDefault(v variables.VariableType, err error) {
+	if r, ok := sc.ScanContext.(variables.DefaultRecorder); ok {
+		r.RecordDefault(v, err)
+	}
+}
+
+// HandleValueError sets the path of the value errors to the overridden file path before forwarding them to the
+// overridden scan context, so that the errors of the archive members do not report the archive path.
+func (sc *fileScanContext) HandleValueError(d variables.VariableDefiner, v variables.VariableType, err error) error {
+	var verr *variables.ValueError
+	if errors.As(err, &verr) {
+		verr.Path = sc.path
+	}
+	return sc.ScanContext.HandleValueError(d, v, err)
+}
+
+// ratioReader fails reading when the data read exceeds the compression ratio limit.
+type ratioReader struct {
+	r          io.Reader
+	compressed int64
+	ratio      float64
+	n          int64
+}
+
+func (rr *ratioReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.n += int64(n)
+	if rr.n > archiveRatioMinSize && float64(rr.n) > float64(rr.compressed)*rr.ratio {
+		return n, fmt.Errorf("%w: compression ratio exceeds %g", ErrArchiveLimit, rr.ratio)
+	}
+	return n, err
+}
+
+// archiveMemberPath returns the virtual path of the given member of the given archive.
+func archiveMemberPath(archive, member string) string {
+	member = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(member, "\\", "/")), "/")
+	return archive + ArchiveSeparator + member
+}
+
+// gzipMemberName returns the name of the member of the given gzip file without a name in its header.
+func gzipMemberName(name string) string {
+	switch ext := path.Ext(name); strings.ToLower(ext) {
+	case ".tgz":
+		return strings.TrimSuffix(name, ext) + ".tar"
+	case ".gz":
+		return strings.TrimSuffix(name, ext)
+	}
+	return name
+}
+
+// isTarHeader reports whether the given header is the header of a tar archive.
+func isTarHeader(header []byte) bool {
+	return len(header) >= 262 && string(header[257:262]) == "ustar"
+}
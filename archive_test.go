@@ -0,0 +1,148 @@
+package gora_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hillu/go-yara/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+	"github.com/binalyze/gora/variables"
+)
+
+func TestScanArchive(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var tgz bytes.Buffer
+	gw := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gw)
+	content := "nested test"
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "lib/y.js", Mode: 0o644, Size: int64(len(content))}))
+	_, err := tw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	w, err := zw.Create("docs/x.js")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("a test"))
+	require.NoError(t, err)
+	w, err = zw.Create("docs/readme.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("a test"))
+	require.NoError(t, err)
+	w, err = zw.Create("inner.tar.gz")
+	require.NoError(t, err)
+	_, err = w.Write(tgz.Bytes())
+	require.NoError(t, err)
+	w, err = zw.Create("bomb.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(strings.Repeat("a", 4<<20)))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	path := filepath.Join(tempDir, "a.zip")
+	require.NoError(t, os.WriteFile(path, zipBuf.Bytes(), 0o644))
+
+	comp := gora.NewCompiled()
+	err = comp.CompileString(`rule js { strings: $a = "test" condition: file_extension == "js" and $a }`, "")
+	require.NoError(t, err)
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	var matches yara.MatchRules
+	comp.SetCallback(&matches)
+
+	var sctx variables.ScanContextImpl
+	sctx.SetInFileSystem(true)
+	sctx.SetFilePath(path)
+
+	matched := map[string]bool{}
+	var limitErr error
+	err = comp.ScanArchive(path, &sctx, gora.ArchiveOptions{SpoolSize: 8}, func(p string, err error) error {
+		if errors.Is(err, gora.ErrArchiveLimit) {
+			limitErr = err
+			return nil
+		}
+		require.NoError(t, err)
+		matched[p] = len(matches) > 0
+		matches = nil
+		return nil
+	})
+	require.NoError(t, err)
+	require.ErrorIs(t, limitErr, gora.ErrArchiveLimit)
+	require.Equal(t, map[string]bool{
+		path:                              false,
+		path + "!/docs/x.js":              true,
+		path + "!/docs/readme.txt":        false,
+		path + "!/inner.tar.gz":           false,
+		path + "!/inner.tar.gz!/lib/y.js": true,
+	}, matched)
+
+	// the nested archives are not extracted deeper than the maximum depth.
+	matched = map[string]bool{}
+	err = comp.ScanArchive(path, &sctx, gora.ArchiveOptions{MaxDepth: 1}, func(p string, err error) error {
+		matched[p] = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotContains(t, matched, path+"!/inner.tar.gz!/lib/y.js")
+	require.Contains(t, matched, path+"!/inner.tar.gz")
+}
+
+func TestScanArchiveValueError(t *testing.T) {
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	w, err := zw.Create("docs/x.js")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("a test"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	path := filepath.Join(t.TempDir(), "a.zip")
+	require.NoError(t, os.WriteFile(path, zipBuf.Bytes(), 0o644))
+
+	orig := variables.Valuers
+	t.Cleanup(func() {
+		variables.Valuers = orig
+	})
+	errValuer := errors.New("valuer failed")
+	variables.Valuers[variables.VarFileExtension] = variables.ValueFunc(func(_ variables.ScanContext) (interface{}, error) {
+		return nil, errValuer
+	})
+
+	comp := gora.NewCompiled()
+	comp.SetVariables([]variables.VariableType{variables.VarFileExtension})
+	require.NoError(t, comp.CompileString(`rule js { condition: file_extension == "js" }`, ""))
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	var collector variables.ValueErrorCollector
+	var sctx variables.ScanContextImpl
+	sctx.SetInFileSystem(true)
+	sctx.SetFilePath(path)
+	sctx.SetHandleValueError(collector.Handle)
+
+	err = comp.ScanArchive(path, &sctx, gora.ArchiveOptions{}, func(_ string, err error) error {
+		return err
+	})
+	require.NoError(t, err)
+
+	// the value errors of the members report the member paths.
+	var paths []string
+	for _, verr := range collector.Errors() {
+		require.ErrorIs(t, verr, errValuer)
+		paths = append(paths, verr.Path)
+	}
+	require.ElementsMatch(t, []string{path, path + "!/docs/x.js"}, paths)
+}
@@ -0,0 +1,163 @@
+package gora
+
+import (
+	"context"
+
+	"github.com/hillu/go-yara/v4"
+)
+
+// scanCallback wraps the yara.ScanCallback set by SetCallback during the scans to abort the scan when the context is
+// done or the scanner options limit the scan, to record the limit stopping the scan, and to collect the evidence of
+// the matching rules if an evidence collector is set. It implements the optional callback interfaces, except
+// yara.ScanCallbackNoMatch, and forwards them to the wrapped callback if it implements them. See scanCallbackNoMatch.
+type scanCallback struct {
+	ctx           context.Context
+	cb            yara.ScanCallback
+	maxMatches    int
+	reportNoMatch bool
+
+	evidence *EvidenceCollector
+	source   *evidenceSource
+
+	cancelled bool
+	limit     ScanLimit
+}
+
+// scanCallbackNoMatch is the scanCallback implementing yara.ScanCallbackNoMatch, which makes libyara report the rules
+// not matching as well. It is used if the rules not matching are reported to the wrapped callback, or to check the
+// context at more points of the scan.
+type scanCallbackNoMatch struct {
+	*scanCallback
+}
+
+var (
+	_ yara.ScanCallback                     = (*scanCallback)(nil)
+	_ yara.ScanCallbackFinished             = (*scanCallback)(nil)
+	_ yara.ScanCallbackModuleImport         = (*scanCallback)(nil)
+	_ yara.ScanCallbackModuleImportFinished = (*scanCallback)(nil)
+	_ yara.ScanCallbackConsoleLog           = (*scanCallback)(nil)
+	_ yara.ScanCallbackTooManyMatches       = (*scanCallback)(nil)
+	_ yara.ScanCallbackNoMatch              = scanCallbackNoMatch{}
+)
+
+func newScanCallback(ctx context.Context, cb yara.ScanCallback, opts *ScannerOptions) *scanCallback {
+	_, noMatch := cb.(yara.ScanCallbackNoMatch)
+	sc := &scanCallback{
+		ctx:           ctx,
+		cb:            cb,
+		reportNoMatch: noMatch,
+	}
+	if opts != nil {
+		sc.maxMatches = opts.MaxMatchesPerString
+		sc.reportNoMatch = noMatch && !opts.SkipNonMatching
+	}
+	return sc
+}
+
+// wrap returns the callback to be set to the scanner. If checkNoMatch is true, the rules not matching are reported
+// to check the context even if they are not reported to the wrapped callback.
+func (sc *scanCallback) wrap(checkNoMatch bool) yara.ScanCallback {
+	if sc.reportNoMatch || checkNoMatch {
+		return scanCallbackNoMatch{sc}
+	}
+	return sc
+}
+
+func (sc *scanCallback) done() bool {
+	if !sc.cancelled && sc.ctx.Err() != nil {
+		sc.cancelled = true
+	}
+	return sc.cancelled
+}
+
+// exceedsMaxMatches reports whether a string of the given matching rule has more matches than the maximum.
+func (sc *scanCallback) exceedsMaxMatches(ctx *yara.ScanContext, r *yara.Rule) bool {
+	if sc.maxMatches <= 0 {
+		return false
+	}
+	for _, s := range r.Strings() {
+		if len(s.Matches(ctx)) > sc.maxMatches {
+			return true
+		}
+	}
+	return false
+}
+
+func (sc *scanCallback) RuleMatching(ctx *yara.ScanContext, r *yara.Rule) (bool, error) {
+	if sc.done() {
+		return true, nil
+	}
+	var (
+		abort bool
+		err   error
+	)
+	if sc.evidence != nil {
+		sc.evidence.collect(sc.source, ctx, r)
+	}
+	if sc.cb != nil {
+		abort, err = sc.cb.RuleMatching(ctx, r)
+	}
+	if !abort && err == nil && sc.exceedsMaxMatches(ctx, r) {
+		sc.limit = LimitMaxMatches
+		abort = true
+	}
+	return abort, err
+}
+
+func (sc scanCallbackNoMatch) RuleNotMatching(ctx *yara.ScanContext, r *yara.Rule) (bool, error) {
+	if sc.done() {
+		return true, nil
+	}
+	if !sc.reportNoMatch {
+		return false, nil
+	}
+	return sc.cb.(yara.ScanCallbackNoMatch).RuleNotMatching(ctx, r)
+}
+
+func (sc *scanCallback) ScanFinished(ctx *yara.ScanContext) (bool, error) {
+	if cb, ok := sc.cb.(yara.ScanCallbackFinished); ok {
+		return cb.ScanFinished(ctx)
+	}
+	return false, nil
+}
+
+func (sc *scanCallback) ImportModule(ctx *yara.ScanContext, name string) ([]byte, bool, error) {
+	if sc.done() {
+		return nil, true, nil
+	}
+	if cb, ok := sc.cb.(yara.ScanCallbackModuleImport); ok {
+		return cb.ImportModule(ctx, name)
+	}
+	return nil, false, nil
+}
+
+func (sc *scanCallback) ModuleImported(ctx *yara.ScanContext, obj *yara.Object) (bool, error) {
+	if sc.done() {
+		return true, nil
+	}
+	if cb, ok := sc.cb.(yara.ScanCallbackModuleImportFinished); ok {
+		return cb.ModuleImported(ctx, obj)
+	}
+	return false, nil
+}
+
+func (sc *scanCallback) ConsoleLog(ctx *yara.ScanContext, msg string) {
+	if cb, ok := sc.cb.(yara.ScanCallbackConsoleLog); ok {
+		cb.ConsoleLog(ctx, msg)
+	}
+}
+
+// TooManyMatches is called by libyara when a string reaches its internal match limit. The matching of the string
+// stops, and the limit is recorded.
+func (sc *scanCallback) TooManyMatches(ctx *yara.ScanContext, r *yara.Rule, s string) (bool, error) {
+	if sc.done() {
+		return true, nil
+	}
+	if sc.limit == LimitNone {
+		sc.limit = LimitTooManyMatches
+	}
+	if cb, ok := sc.cb.(yara.ScanCallbackTooManyMatches); ok {
+		return cb.TooManyMatches(ctx, r, s)
+	}
+	return false, nil
+}
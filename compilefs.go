@@ -0,0 +1,147 @@
+package gora
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/hillu/go-yara/v4"
+)
+
+// CompileFS compiles the YARA rules in the given file system, such as an embed.FS of the rules shipped within the
+// binary. The rule files are selected by the given fs.Glob patterns, and the directories matching them are walked for
+// the files with .yar or .yara extensions. All the rule files in the file system are compiled if no pattern is given.
+//
+// The namespace of each rule file is its slash separated path in the file system, such as "windows/malware.yar". The
+// includes are resolved in the same file system, relative to the directory of the including file, therefore the
+// rules never read the host file system.
+func (c *Compiled) CompileFS(fsys fs.FS, patterns ...string) error {
+	if c.rules != nil {
+		return ErrAlreadyCompiled
+	}
+
+	paths, err := ruleFSPaths(fsys, patterns)
+	if err != nil {
+		return err
+	}
+
+	ruleNs := make([]RuleNamespace, 0, len(paths))
+	for _, p := range paths {
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		ruleNs = append(ruleNs, RuleNamespace{Rule: string(b), Namespace: p})
+	}
+
+	inc := &fsIncluder{fsys: fsys}
+	return c.compileStrings(ruleNs, func(compiler *yara.Compiler) {
+		compiler.SetIncludeCallback(inc.include)
+	}, func(i int) {
+		inc.current = paths[i]
+	})
+}
+
+// ruleFSPaths returns the paths of the rule files in the given file system matching the given patterns.
+func ruleFSPaths(fsys fs.FS, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no yara files matching '%s'", pattern)
+		}
+		for _, match := range matches {
+			err = fs.WalkDir(fsys, match, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.Type().IsRegular() || seen[p] {
+					return nil
+				}
+				// the files matching the patterns are compiled regardless of their extensions.
+				if p != match && !isRuleFile(p) {
+					return nil
+				}
+				seen[p] = true
+				paths = append(paths, p)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no yara files")
+	}
+	return paths, nil
+}
+
+func isRuleFile(p string) bool {
+	ext := path.Ext(p)
+	return strings.EqualFold(ext, ".yar") || strings.EqualFold(ext, ".yara")
+}
+
+// fsIncluder resolves the includes of the rules compiled by CompileFS in their file system.
+type fsIncluder struct {
+	fsys fs.FS
+	// current is the path of the rule file being compiled.
+	current string
+	// includes is the stack of the included files of the rule file being compiled, to resolve the nested includes
+	// relative to the paths of the including files.
+	includes []fsInclude
+}
+
+// fsInclude is an included file of the rule file being compiled.
+type fsInclude struct {
+	// name is the name of the file in the include statement, which is the filename yara reports for its includes.
+	name string
+	// path is the path of the file in the file system.
+	path string
+}
+
+// include is the yara.CompilerIncludeFunc of CompileFS. The including file is the rule file being compiled if the
+// filename is empty, since the rules are added as strings. Otherwise, yara reports the name in the include statement
+// of the including file as the filename, and the including file is the innermost included file with that name, since
+// the included files are compiled before the rest of the including file and yara rejects the circular includes.
+func (inc *fsIncluder) include(name, filename, _ string) []byte {
+	including := inc.current
+	if filename == "" {
+		inc.includes = inc.includes[:0]
+	} else {
+		for len(inc.includes) > 0 && inc.includes[len(inc.includes)-1].name != filename {
+			inc.includes = inc.includes[:len(inc.includes)-1]
+		}
+		if len(inc.includes) > 0 {
+			including = inc.includes[len(inc.includes)-1].path
+		} else if fs.ValidPath(filename) {
+			including = filename
+		}
+	}
+
+	p := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if !path.IsAbs(p) {
+		p = path.Join(path.Dir(including), p)
+	}
+	p = strings.TrimPrefix(p, "/")
+	if !fs.ValidPath(p) {
+		return nil
+	}
+
+	b, err := fs.ReadFile(inc.fsys, p)
+	if err != nil {
+		return nil
+	}
+	inc.includes = append(inc.includes, fsInclude{name: name, path: p})
+	return b
+}
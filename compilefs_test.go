@@ -0,0 +1,53 @@
+package gora_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+)
+
+func TestCompileFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"rules/common/strings.yar": {Data: []byte(`rule common { strings: $a = "test" condition: $a }`)},
+		"rules/windows/mal.yar": {Data: []byte(`include "../common/strings.yar"
+			rule mal { condition: common and os_windows }`)},
+		"rules/linux/mal.yara": {Data: []byte(`rule mal { condition: os_linux }`)},
+		"rules/readme.md":      {Data: []byte(`not a rule`)},
+		"nested/main.yar": {Data: []byte(`include "lib/inc.yar"
+			include "inc.yar"
+			rule main { condition: lib and top }`)},
+		"nested/lib/inc.yar":    {Data: []byte(`include "common.yar"`)},
+		"nested/lib/common.yar": {Data: []byte(`rule lib { condition: true }`)},
+		"nested/inc.yar":        {Data: []byte(`include "common.yar"`)},
+		"nested/common.yar":     {Data: []byte(`rule top { condition: true }`)},
+		"bad/escape.yar":        {Data: []byte(`include "../../etc/passwd"`)},
+		"bad/missing.yar":       {Data: []byte(`include "missing.yar"`)},
+	}
+
+	comp := gora.NewCompiled()
+	require.NoError(t, comp.CompileFS(fsys, "rules/windows", "rules/linux/*.yara"))
+	defer comp.Destroy()
+
+	namespaces := map[string]bool{}
+	for _, r := range comp.Rules().GetRules() {
+		namespaces[r.Namespace()+":"+r.Identifier()] = true
+	}
+	require.Equal(t, map[string]bool{
+		"rules/windows/mal.yar:common": true,
+		"rules/windows/mal.yar:mal":    true,
+		"rules/linux/mal.yara:mal":     true,
+	}, namespaces)
+
+	// the nested includes with the same names are resolved relative to their including files.
+	nested := gora.NewCompiled()
+	require.NoError(t, nested.CompileFS(fsys, "nested/main.yar"))
+	defer nested.Destroy()
+	require.Len(t, nested.Rules().GetRules(), 3)
+
+	require.Error(t, gora.NewCompiled().CompileFS(fsys, "bad/escape.yar"))
+	require.Error(t, gora.NewCompiled().CompileFS(fsys, "bad/missing.yar"))
+	require.Error(t, gora.NewCompiled().CompileFS(fsys, "nothing/*"))
+}
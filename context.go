@@ -0,0 +1,174 @@
+package gora
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// ErrCancelled is matched by the errors returned when a scan is cancelled or its deadline is exceeded. Use
+// errors.Is(err, ErrCancelled) to tell a cancelled scan apart from a failed one.
+var ErrCancelled = errors.New("scan cancelled")
+
+// CancelledError is returned when a scan is aborted since its context is done. It wraps the context error, so
+// errors.Is also matches context.Canceled or context.DeadlineExceeded.
+type CancelledError struct {
+	Err error
+}
+
+// Error implements the error interface.
+func (e *CancelledError) Error() string {
+	return "scan cancelled: " + e.Err.Error()
+}
+
+// Unwrap returns the context error.
+func (e *CancelledError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is ErrCancelled.
+func (e *CancelledError) Is(target error) bool {
+	return target == ErrCancelled
+}
+
+// ScanOptions holds the settings shared by the methods scanning many targets, such as ScanFS, ScanImage,
+// ScanDeletedFiles and ScanProcesses. It is embedded in their options.
+type ScanOptions struct {
+	// Context is set to the scan contexts, and the scanning stops when it is done. context.Background() is used if it
+	// is nil.
+	Context context.Context
+	// HandleValueError is set to the scan contexts as the value error handler.
+	HandleValueError variables.ValueErrorHandler
+}
+
+// ctx returns the context of the scanning.
+func (o *ScanOptions) ctx() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+// resetScanContext resets the given scan context for the next target, and sets the context and the value error
+// handler to it.
+func (o *ScanOptions) resetScanContext(sctx *variables.ScanContextImpl) {
+	sctx.Reset()
+	sctx.SetContext(o.ctx())
+	sctx.SetHandleValueError(o.HandleValueError)
+}
+
+// cancelledError wraps the given error in a *CancelledError if it is a context error.
+func cancelledError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var cerr *CancelledError
+	if errors.As(err, &cerr) {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return &CancelledError{Err: err}
+	}
+	return err
+}
+
+// ScanFileContext scans the given file like ScanFile, and aborts the scan when the given context is done. See
+// scanContext for the details. The errors are returned as *ScanError, see Classify.
+func (c *Compiled) ScanFileContext(ctx context.Context, filename string) error {
+	return c.scanFile(ctx, filename, &evidenceSource{path: filename, file: filename})
+}
+
+// scanFile scans the given file like ScanFileContext, collecting the evidence from the given source.
+func (c *Compiled) scanFile(ctx context.Context, filename string, src *evidenceSource) error {
+	err := c.scanContext(ctx, true, src, func() error {
+		return c.scanner.ScanFile(filename)
+	})
+	return fileScanError(err, filename)
+}
+
+// ScanFileDescriptorContext scans the given file descriptor like ScanFileDescriptor, and aborts the scan when the
+// given context is done. See scanContext for the details. The errors are returned as *ScanError.
+func (c *Compiled) ScanFileDescriptorContext(ctx context.Context, fd uintptr) error {
+	return c.scanFileDescriptor(ctx, fd, nil)
+}
+
+// scanFileDescriptor scans the given file descriptor like ScanFileDescriptorContext, collecting the evidence from the
+// given source.
+func (c *Compiled) scanFileDescriptor(ctx context.Context, fd uintptr, src *evidenceSource) error {
+	err := c.scanContext(ctx, true, src, func() error {
+		return c.scanner.ScanFileDescriptor(fd)
+	})
+	return fileScanError(err, "")
+}
+
+// ScanProcContext scans the given process like ScanProc, and aborts the scan when the given context is done. See
+// scanContext for the details. The errors are returned as *ScanError, see Classify.
+func (c *Compiled) ScanProcContext(ctx context.Context, pid int) error {
+	err := c.scanContext(ctx, false, &evidenceSource{pid: pid}, func() error {
+		return c.scanner.ScanProc(pid)
+	})
+	return procScanError(err, pid)
+}
+
+// scanContext runs the given scan function with the given context. A *CancelledError is returned if the context is
+// done before or during the scan. If skippable is true, the scan is skipped if the prefilter excluded the target in
+// DefineScannerVariables. The evidence of the matching rules is collected from the given source if an evidence
+// collector is set.
+//
+// The callback of the scanner, set either by SetCallback or directly on Scanner, is wrapped during the scan and
+// restored after it.
+//
+// libyara cannot be interrupted while it is matching the strings, therefore the context is checked at every callback
+// of the scan, and the context deadline is mapped to the scanner timeout to stop the string matching. Since the
+// scanner timeout has a resolution of seconds, the scan may take up to a second longer than the deadline. libyara does
+// not report the timeout of a scanner, so the timeout set by SetScannerOptions is restored after the scans with a
+// deadline, and a timeout set directly on Scanner is not kept.
+func (c *Compiled) scanContext(ctx context.Context, skippable bool, src *evidenceSource, scan func() error) error {
+	c.limit = LimitNone
+	if skip := c.consumeSkip(); skip && skippable {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return &CancelledError{Err: err}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := deadlineTimeout(deadline, c.timeout)
+		c.scanner.SetTimeout(timeout)
+		defer c.scanner.SetTimeout(c.timeout)
+	}
+
+	prev := c.scanner.Callback
+	cb := newScanCallback(ctx, prev, c.options)
+	if c.evidence != nil {
+		cb.evidence, cb.source = c.evidence, src
+	}
+	c.scanner.SetCallback(cb.wrap(ctx.Done() != nil))
+	defer c.scanner.SetCallback(prev)
+
+	err := scan()
+	if ctxErr := ctx.Err(); ctxErr != nil && (cb.cancelled || err != nil) {
+		return &CancelledError{Err: ctxErr}
+	}
+	c.limit = cb.limit
+	if isYaraError(err, errScanTimeout) {
+		c.limit = LimitTimeout
+	}
+	return err
+}
+
+// deadlineTimeout returns the scanner timeout for the given deadline. The timeout is rounded up to seconds, and it is
+// never longer than the given scanner timeout if it is set.
+func deadlineTimeout(deadline time.Time, timeout time.Duration) time.Duration {
+	remaining := time.Until(deadline)
+	if remaining < time.Second {
+		remaining = time.Second
+	}
+	remaining = (remaining + time.Second - 1).Truncate(time.Second)
+	if timeout > 0 && timeout < remaining {
+		return timeout
+	}
+	return remaining
+}
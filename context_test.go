@@ -0,0 +1,83 @@
+package gora_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hillu/go-yara/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+	"github.com/binalyze/gora/variables"
+)
+
+func TestScanFileContext(t *testing.T) {
+	tempDir := t.TempDir()
+	path := genFile(t, tempDir, "test")
+
+	comp := gora.NewCompiled()
+	err := comp.CompileString(`rule x { strings: $a = "test" condition: $a }`, "")
+	require.NoError(t, err)
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	var matches yara.MatchRules
+	comp.SetCallback(&matches)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, comp.ScanFileContext(ctx, path))
+	require.Len(t, matches, 1)
+
+	cancel()
+	matches = nil
+	err = comp.ScanFileContext(ctx, path)
+	require.True(t, errors.Is(err, gora.ErrCancelled))
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Empty(t, matches)
+
+	// the callback is restored after the scans.
+	require.NoError(t, comp.ScanFile(path))
+	require.Len(t, matches, 1)
+}
+
+func TestScanFileScannerCallback(t *testing.T) {
+	tempDir := t.TempDir()
+	path := genFile(t, tempDir, "test")
+
+	comp := gora.NewCompiled()
+	require.NoError(t, comp.CompileString(`rule x { strings: $a = "test" condition: $a }`, ""))
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	// the callback set directly on the scanner is used by the scans and kept after them.
+	var matches yara.MatchRules
+	comp.Scanner().SetCallback(&matches)
+	require.NoError(t, comp.ScanFile(path))
+	require.Len(t, matches, 1)
+	require.Equal(t, &matches, comp.Scanner().Callback)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, comp.ScanFileContext(ctx, path))
+	require.Len(t, matches, 2)
+	require.Equal(t, &matches, comp.Scanner().Callback)
+}
+
+func TestDefineScannerVariablesCancelled(t *testing.T) {
+	comp := gora.NewCompiled()
+	require.NoError(t, comp.CompileString(`rule x { condition: file_name == "a" }`, ""))
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var sctx variables.ScanContextImpl
+	sctx.SetContext(ctx)
+	err := comp.DefineScannerVariables(&sctx)
+
+	var cerr *gora.CancelledError
+	require.True(t, errors.As(err, &cerr))
+	require.True(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,34 @@
+package gora
+
+import (
+	"errors"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// ErrDeletedFilesUnsupported is returned from FindDeletedFiles and ScanDeletedFiles on the platforms other than Linux.
+var ErrDeletedFilesUnsupported = errors.New("deleted file sweep is not supported on this platform")
+
+// DeletedFile is a deleted file still used by a process, either as its executable or as an open file.
+type DeletedFile struct {
+	// Pid is the id of the process using the file.
+	Pid int
+	// Fd is the file descriptor of the open file, or -1 if the file is the executable of the process.
+	Fd int
+	// Path is the original path of the file, which is set to file_path.
+	Path string
+	// ProcPath is the path the content of the file is read from, such as /proc/<pid>/exe or /proc/<pid>/fd/3.
+	ProcPath string
+}
+
+// DeletedScanOptions holds the settings of ScanDeletedFiles.
+type DeletedScanOptions struct {
+	ScanOptions
+	// Procfs is set to the scan contexts as the procfs settings of the process variables. Its cached socket tables
+	// are reset at the start of the sweep.
+	Procfs *variables.Procfs
+}
+
+// DeletedScanFunc is called by ScanDeletedFiles after each file scanned with the file and the scan error if any.
+// Returning an error stops ScanDeletedFiles, and the error is returned.
+type DeletedScanFunc func(file DeletedFile, err error) error
@@ -0,0 +1,155 @@
+//go:build linux
+// +build linux
+
+package gora
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/shirou/gopsutil/v3/process"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// FindDeletedFiles returns the deleted files still used by the running processes: the executables of the processes
+// whose /proc/<pid>/exe is deleted, and the regular files open by the processes whose /proc/<pid>/fd links are
+// deleted, including the memfd files. The processes which cannot be inspected, due to the permissions or since they
+// exit, are skipped.
+func FindDeletedFiles() ([]DeletedFile, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []DeletedFile
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid <= 0 {
+			continue
+		}
+		files = append(files, processDeletedFiles(pid)...)
+	}
+	return files, nil
+}
+
+// processDeletedFiles returns the deleted files used by the given process.
+func processDeletedFiles(pid int) []DeletedFile {
+	var files []DeletedFile
+	dir := fmt.Sprintf("/proc/%d", pid)
+	if p, ok := variables.DeletedLink(dir + "/exe"); ok {
+		files = append(files, DeletedFile{Pid: pid, Fd: -1, Path: p, ProcPath: dir + "/exe"})
+	}
+
+	fds, err := os.ReadDir(dir + "/fd")
+	if err != nil {
+		return files
+	}
+	for _, entry := range fds {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		procPath := dir + "/fd/" + entry.Name()
+		p, ok := variables.DeletedLink(procPath)
+		if !ok {
+			continue
+		}
+		if info, err := os.Stat(procPath); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, DeletedFile{Pid: pid, Fd: fd, Path: p, ProcPath: procPath})
+	}
+	return files
+}
+
+// fileID identifies a file by its device and inode.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// statFileID returns the id of the file of the given file info.
+func statFileID(info os.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: st.Ino}, true
+}
+
+// ScanDeletedFiles finds the deleted files still used by the running processes using FindDeletedFiles, and scans
+// their content through /proc/<pid>/exe and /proc/<pid>/fd/<fd>. The files are scanned with their original paths as
+// file_path, and the ids of the processes using them as process_id, so the other process variables are defined for
+// the processes as well. file_deleted is true for all of them, and process_exe_deleted is true for the executables.
+//
+// A file used by several processes or file descriptors is scanned once, and reported with the first one found. The
+// given function is called after each file scanned to report the results. If it is nil, ScanDeletedFiles stops at the
+// first error and returns it.
+func (c *Compiled) ScanDeletedFiles(opts DeletedScanOptions, fn DeletedScanFunc) error {
+	ctx := opts.ctx()
+	opts.Procfs = sweepProcfs(opts.Procfs)
+	if fn == nil {
+		fn = func(_ DeletedFile, err error) error {
+			return err
+		}
+	}
+
+	files, err := FindDeletedFiles()
+	if err != nil {
+		return err
+	}
+
+	scanned := make(map[fileID]struct{}, len(files))
+
+	var sctx variables.ScanContextImpl
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return &CancelledError{Err: err}
+		}
+
+		info, err := os.Stat(file.ProcPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err = fn(file, fileScanError(err, file.Path)); err != nil {
+				return err
+			}
+			continue
+		}
+		if id, ok := statFileID(info); ok {
+			if _, ok := scanned[id]; ok {
+				continue
+			}
+			scanned[id] = struct{}{}
+		}
+
+		opts.resetScanContext(&sctx)
+		sctx.SetProcfs(opts.Procfs)
+		sctx.SetInFileSystem(true)
+		sctx.SetFilePath(file.Path)
+		sctx.SetRealPath(file.ProcPath)
+		sctx.SetFileInfo(info)
+		sctx.SetPid(file.Pid)
+		if proc, err := process.NewProcess(int32(file.Pid)); err == nil {
+			sctx.SetProcessInfo(proc)
+		}
+
+		err = c.DefineScannerVariables(&sctx)
+		if err == nil {
+			err = c.scanFile(ctx, file.ProcPath, &evidenceSource{path: file.Path, pid: file.Pid, file: file.ProcPath})
+			var serr *ScanError
+			if errors.As(err, &serr) {
+				serr.Path = file.Path
+			}
+		}
+		if err = fn(file, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package gora
+
+// FindDeletedFiles is not supported on this platform.
+func FindDeletedFiles() ([]DeletedFile, error) {
+	return nil, ErrDeletedFilesUnsupported
+}
+
+// ScanDeletedFiles is not supported on this platform.
+func (c *Compiled) ScanDeletedFiles(DeletedScanOptions, DeletedScanFunc) error {
+	return ErrDeletedFilesUnsupported
+}
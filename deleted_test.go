@@ -0,0 +1,95 @@
+package gora_test
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/hillu/go-yara/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+)
+
+func TestScanDeletedFiles(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("deleted file sweep is supported on linux only")
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep is not found")
+	}
+
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "deleted-sleep")
+	copyFile(t, sleep, exe, 0o755)
+
+	cmd := exec.Command(exe, "30")
+	require.NoError(t, cmd.Start())
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	require.NoError(t, os.Remove(exe))
+
+	open := filepath.Join(dir, "deleted-open")
+	f, err := os.Create(open)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString("deleted content")
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(open))
+
+	files, err := gora.FindDeletedFiles()
+	require.NoError(t, err)
+	require.Contains(t, files, gora.DeletedFile{Pid: cmd.Process.Pid, Fd: -1, Path: exe,
+		ProcPath: filepath.Join("/proc", strconv.Itoa(cmd.Process.Pid), "exe")})
+	require.Contains(t, files, gora.DeletedFile{Pid: os.Getpid(), Fd: int(f.Fd()), Path: open,
+		ProcPath: filepath.Join("/proc", strconv.Itoa(os.Getpid()), "fd", strconv.Itoa(int(f.Fd())))})
+
+	comp := gora.NewCompiled()
+	err = comp.CompileString(`
+	rule exe {
+		condition:
+			file_deleted and process_exe_deleted and uint32(0) == 0x464c457f
+	}
+	rule open {
+		strings:
+			$a = "deleted content"
+		condition:
+			file_deleted and not process_exe_deleted and $a
+	}`, "")
+	require.NoError(t, err)
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	results := make(map[string][]string)
+	var matches yara.MatchRules
+	comp.SetCallback(&matches)
+	err = comp.ScanDeletedFiles(gora.DeletedScanOptions{}, func(file gora.DeletedFile, err error) error {
+		for _, m := range matches {
+			results[file.Path] = append(results[file.Path], m.Rule)
+		}
+		matches = nil
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"exe"}, results[exe])
+	require.Equal(t, []string{"open"}, results[open])
+}
+
+func copyFile(t *testing.T, src, dst string, perm os.FileMode) {
+	t.Helper()
+	in, err := os.Open(src)
+	require.NoError(t, err)
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	require.NoError(t, err)
+	_, err = io.Copy(out, in)
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+}
@@ -0,0 +1,188 @@
+package gora
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/hillu/go-yara/v4"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// The messages of the libyara errors classified.
+const (
+	errScanTimeout               = "scan timeout"
+	errTooManyMatches            = "too many matches"
+	errCouldNotAttach            = "could not attach to process"
+	errCouldNotReadProcessMemory = "could not read process memory"
+	errCouldNotOpenFile          = "could not open file"
+	errCouldNotMapFile           = "could not map file"
+	errCouldNotReadFile          = "could not read file"
+)
+
+// The sentinel errors of the scan failures. They are matched by the *ScanError of their kind using errors.Is.
+var (
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrScanTimeout      = errors.New("scan timeout")
+	ErrTooManyMatches   = errors.New("too many matches")
+	ErrCouldNotAttach   = errors.New("could not attach to process")
+	// ErrVanished is the error of the files or processes which no longer exist at the time of the scan.
+	ErrVanished = errors.New("scan target vanished")
+)
+
+// ErrorKind is the classification of a scan failure.
+type ErrorKind int
+
+const (
+	// KindNone is the kind of nil errors.
+	KindNone ErrorKind = iota
+	// KindOther is the kind of the errors not classified.
+	KindOther
+	// KindCancelled is the kind of the scans cancelled by their contexts. See CancelledError.
+	KindCancelled
+	// KindPermissionDenied is the kind of the scans failed since the target cannot be accessed.
+	KindPermissionDenied
+	// KindTimeout is the kind of the scans stopped by the scanner timeout.
+	KindTimeout
+	// KindTooManyMatches is the kind of the scans failed since a string has too many matches.
+	KindTooManyMatches
+	// KindCouldNotAttach is the kind of the process scans failed to attach to or read the process.
+	KindCouldNotAttach
+	// KindVanished is the kind of the scans failed since the file or process no longer exists.
+	KindVanished
+)
+
+var errorKinds = [...]struct {
+	name string
+	err  error
+}{
+	KindNone:             {"none", nil},
+	KindOther:            {"other", nil},
+	KindCancelled:        {"cancelled", ErrCancelled},
+	KindPermissionDenied: {"permission denied", ErrPermissionDenied},
+	KindTimeout:          {"timeout", ErrScanTimeout},
+	KindTooManyMatches:   {"too many matches", ErrTooManyMatches},
+	KindCouldNotAttach:   {"could not attach", ErrCouldNotAttach},
+	KindVanished:         {"vanished", ErrVanished},
+}
+
+// String implements the fmt.Stringer interface.
+func (k ErrorKind) String() string {
+	if k >= 0 && int(k) < len(errorKinds) {
+		return errorKinds[k].name
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+// Err returns the sentinel error of the kind, or nil if the kind has none.
+func (k ErrorKind) Err() error {
+	if k >= 0 && int(k) < len(errorKinds) {
+		return errorKinds[k].err
+	}
+	return nil
+}
+
+// ScanError is the error returned from the scan methods. It wraps the scan error with its kind and the scan target.
+type ScanError struct {
+	Kind ErrorKind
+	// Path is the path of the scanned file, if known.
+	Path string
+	// Pid is the id of the scanned process, if known.
+	Pid int
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ScanError) Error() string {
+	target := e.Path
+	if e.Pid > 0 {
+		target = "pid " + strconv.Itoa(e.Pid)
+	}
+	if target == "" {
+		return fmt.Sprintf("scan error: %v", e.Err)
+	}
+	return fmt.Sprintf("scan error for %s: %v", target, e.Err)
+}
+
+// Unwrap returns the scan error.
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is the sentinel error of the kind.
+func (e *ScanError) Is(target error) bool {
+	err := e.Kind.Err()
+	return err != nil && target == err
+}
+
+// Classify returns the kind of the given scan error.
+func Classify(err error) ErrorKind {
+	if err == nil {
+		return KindNone
+	}
+	var serr *ScanError
+	if errors.As(err, &serr) {
+		return serr.Kind
+	}
+	return classify(err)
+}
+
+func classify(err error) ErrorKind {
+	switch {
+	case errors.Is(err, ErrCancelled), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return KindCancelled
+	case errors.Is(err, ErrPermissionDenied), errors.Is(err, os.ErrPermission):
+		return KindPermissionDenied
+	case errors.Is(err, ErrVanished), errors.Is(err, os.ErrNotExist):
+		return KindVanished
+	case errors.Is(err, ErrScanTimeout), isYaraError(err, errScanTimeout):
+		return KindTimeout
+	case errors.Is(err, ErrTooManyMatches), isYaraError(err, errTooManyMatches):
+		return KindTooManyMatches
+	case errors.Is(err, ErrCouldNotAttach), isYaraError(err, errCouldNotAttach),
+		isYaraError(err, errCouldNotReadProcessMemory):
+		return KindCouldNotAttach
+	}
+	return KindOther
+}
+
+// fileScanError returns the *ScanError of the given file scan error. libyara reports the files which cannot be
+// opened without the reason, therefore the file is checked to tell the permission errors and the vanished files.
+func fileScanError(err error, path string) error {
+	if err == nil {
+		return nil
+	}
+	if path != "" && (isYaraError(err, errCouldNotOpenFile) || isYaraError(err, errCouldNotMapFile) ||
+		isYaraError(err, errCouldNotReadFile)) {
+		if f, oerr := os.Open(path); oerr != nil {
+			err = fmt.Errorf("%w: %w", err, oerr)
+		} else {
+			_ = f.Close()
+		}
+	}
+	return &ScanError{Kind: classify(err), Path: path, Err: err}
+}
+
+// procScanError returns the *ScanError of the given process scan error. libyara reports the processes which cannot
+// be attached to without the reason, therefore the process is checked to tell the vanished processes.
+func procScanError(err error, pid int) error {
+	if err == nil {
+		return nil
+	}
+	kind := classify(err)
+	if kind == KindCouldNotAttach {
+		if exists, perr := process.PidExists(int32(pid)); perr == nil && !exists {
+			kind = KindVanished
+		}
+	}
+	return &ScanError{Kind: kind, Pid: pid, Err: err}
+}
+
+// isYaraError reports whether the given error is a libyara error with the given message. The libyara error codes are
+// not exported by go-yara, so the errors are identified by their messages.
+func isYaraError(err error, msg string) bool {
+	var yerr yara.Error
+	return errors.As(err, &yerr) && yerr.Error() == msg
+}
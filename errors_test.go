@@ -0,0 +1,59 @@
+package gora_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+)
+
+func TestClassify(t *testing.T) {
+	require.Equal(t, gora.KindNone, gora.Classify(nil))
+	require.Equal(t, gora.KindOther, gora.Classify(errors.New("test error")))
+	require.Equal(t, gora.KindCancelled, gora.Classify(&gora.CancelledError{Err: context.Canceled}))
+	require.Equal(t, gora.KindPermissionDenied, gora.Classify(os.ErrPermission))
+	require.Equal(t, gora.KindVanished, gora.Classify(os.ErrNotExist))
+
+	err := &gora.ScanError{Kind: gora.KindTimeout, Path: "/a", Err: errors.New("test error")}
+	require.Equal(t, gora.KindTimeout, gora.Classify(err))
+	require.ErrorIs(t, err, gora.ErrScanTimeout)
+	require.NotErrorIs(t, err, gora.ErrVanished)
+	require.Equal(t, "scan error for /a: test error", err.Error())
+	require.Equal(t, "timeout", gora.KindTimeout.String())
+}
+
+func TestScanErrors(t *testing.T) {
+	tempDir := t.TempDir()
+
+	comp := gora.NewCompiled()
+	require.NoError(t, comp.CompileString(`rule x { condition: true }`, ""))
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	path := filepath.Join(tempDir, "vanished")
+	err := comp.ScanFile(path)
+	require.ErrorIs(t, err, gora.ErrVanished)
+
+	var serr *gora.ScanError
+	require.True(t, errors.As(err, &serr))
+	require.Equal(t, path, serr.Path)
+	require.Equal(t, gora.KindVanished, serr.Kind)
+
+	if os.Geteuid() > 0 {
+		path = genFile(t, tempDir, "test")
+		require.NoError(t, os.Chmod(path, 0))
+		require.Equal(t, gora.KindPermissionDenied, gora.Classify(comp.ScanFile(path)))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = comp.ScanProcContext(ctx, os.Getpid())
+	require.Equal(t, gora.KindCancelled, gora.Classify(err))
+	require.True(t, errors.As(err, &serr))
+	require.Equal(t, os.Getpid(), serr.Pid)
+}
@@ -0,0 +1,324 @@
+package gora
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hillu/go-yara/v4"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// The default evidence collection limits. See EvidenceOptions.
+const (
+	DefaultEvidenceWindow               = 256
+	DefaultEvidenceMaxCaptureSize int64 = 16 << 20
+	DefaultEvidenceMaxTotalSize   int64 = 1 << 30
+)
+
+// EvidenceOptions holds the settings of an EvidenceCollector. Zero values use the defaults.
+type EvidenceOptions struct {
+	// Dir is the directory the evidence is written to. It is created if it does not exist.
+	Dir string
+	// Window is the number of bytes captured before and after each match.
+	Window int
+	// FullRegion captures the whole memory regions of the matches in the process memory scans of ScanProcMemory,
+	// instead of the windows around the matches.
+	FullRegion bool
+	// MaxCaptureSize is the maximum size of each capture. The captures exceeding it are truncated.
+	MaxCaptureSize int64
+	// MaxTotalSize is the maximum number of bytes captured by the collector. Once it is reached, only the sidecars of
+	// the matching rules are written.
+	MaxTotalSize int64
+	// Redact is called with each capture before it is written if it is not nil, and the data it returns is written
+	// instead. It may modify the data in place, and it should set Redacted of the capture if it changes the data.
+	Redact func(ev *Evidence, c *EvidenceCapture, data []byte) []byte
+	// OnError is called with the errors of collecting the evidence if it is not nil. The scans are not affected by
+	// them.
+	OnError func(error)
+}
+
+// Evidence is the sidecar of the evidence collected for a matching rule. It is written as JSON next to the data file
+// holding the captured bytes.
+type Evidence struct {
+	Rule      string `json:"rule"`
+	Namespace string `json:"namespace"`
+	// Path is the path of the scanned file, or the file path of the scan context in the in-memory scans.
+	Path string `json:"path,omitempty"`
+	// Pid is the id of the scanned process.
+	Pid int `json:"pid,omitempty"`
+	// Region is the scanned memory region in the process memory scans of ScanProcMemory.
+	Region   *variables.MemoryRegion `json:"region,omitempty"`
+	Matches  []EvidenceMatch         `json:"matches"`
+	Captures []EvidenceCapture       `json:"captures"`
+	// DataFile is the name of the data file in the evidence directory, or empty if no bytes are captured.
+	DataFile string    `json:"data_file,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// EvidenceMatch is a string match of a matching rule.
+type EvidenceMatch struct {
+	String string `json:"string"`
+	// Offset is the virtual address of the match in the process scans, and its offset in the file or the buffer
+	// otherwise.
+	Offset uint64 `json:"offset"`
+	// Length is the length of the match data, which is limited by ScannerOptions.MaxMatchData.
+	Length int `json:"length"`
+}
+
+// EvidenceCapture is a range of the bytes captured.
+type EvidenceCapture struct {
+	// Start is the address or the offset of the first byte captured, like EvidenceMatch.Offset.
+	Start uint64 `json:"start"`
+	// Size is the number of the bytes written to the data file.
+	Size int `json:"size"`
+	// DataOffset is the offset of the bytes in the data file.
+	DataOffset int64 `json:"data_offset"`
+	// Truncated is true if the capture is limited by the size limits.
+	Truncated bool `json:"truncated,omitempty"`
+	// Redacted is set by the redaction hook if it changes the data.
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// EvidenceCollector captures the bytes around the matches of the matching rules, before the evidence disappears with
+// the scanned process or file. For each matching rule, the captured bytes are written to a data file and an Evidence
+// is written to a JSON sidecar, such as "000001-rule.bin" and "000001-rule.json" in the evidence directory.
+//
+// The bytes are read from the scanned file, buffer or process memory region when the rule matches. If the scanned data
+// cannot be read again, such as in ScanProc and the chunked ScanReader, the match data reported by libyara is captured
+// instead. Set it to the scanners using Compiled.SetEvidenceCollector. It is safe to share it between the scanners.
+type EvidenceCollector struct {
+	opts EvidenceOptions
+
+	mu    sync.Mutex
+	seq   int
+	total int64
+}
+
+// evidenceSource is the scan target the evidence is read from.
+type evidenceSource struct {
+	path   string
+	pid    int
+	region *variables.MemoryRegion
+	// r reads the scanned data at the offsets of the matches. It is nil if the data cannot be read again.
+	r io.ReaderAt
+	// file is the path of the file opened to read the data if r is nil.
+	file string
+}
+
+// NewEvidenceCollector returns an EvidenceCollector writing to the directory of the given options.
+func NewEvidenceCollector(opts EvidenceOptions) (*EvidenceCollector, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("evidence directory is not set")
+	}
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &EvidenceCollector{opts: opts.withDefaults()}, nil
+}
+
+func (o EvidenceOptions) withDefaults() EvidenceOptions {
+	if o.Window <= 0 {
+		o.Window = DefaultEvidenceWindow
+	}
+	if o.MaxCaptureSize <= 0 {
+		o.MaxCaptureSize = DefaultEvidenceMaxCaptureSize
+	}
+	if o.MaxTotalSize <= 0 {
+		o.MaxTotalSize = DefaultEvidenceMaxTotalSize
+	}
+	return o
+}
+
+// TotalSize returns the number of bytes captured by the collector.
+func (ec *EvidenceCollector) TotalSize() int64 {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	return ec.total
+}
+
+// collect collects the evidence of the given matching rule from the given source.
+func (ec *EvidenceCollector) collect(src *evidenceSource, sc *yara.ScanContext, r *yara.Rule) {
+	if src == nil {
+		src = &evidenceSource{}
+	}
+	ev := &Evidence{
+		Rule:      r.Identifier(),
+		Namespace: r.Namespace(),
+		Path:      src.path,
+		Pid:       src.pid,
+		Region:    src.region,
+		Time:      time.Now(),
+	}
+
+	var matchData [][]byte
+	for _, s := range r.Strings() {
+		for _, m := range s.Matches(sc) {
+			data := m.Data()
+			ev.Matches = append(ev.Matches, EvidenceMatch{
+				String: s.Identifier(),
+				Offset: uint64(m.Base() + m.Offset()),
+				Length: len(data),
+			})
+			matchData = append(matchData, data)
+		}
+	}
+
+	ra, closeFn, err := src.reader()
+	if err != nil {
+		ec.error(err)
+	}
+	defer closeFn()
+
+	var out bytes.Buffer
+	if ra == nil {
+		for i, m := range ev.Matches {
+			ec.capture(ev, &out, m.Offset, matchData[i], false)
+		}
+	} else {
+		for _, rng := range ec.ranges(ev.Matches, src.region) {
+			data, truncated, err := ec.read(ra, rng[0], rng[1])
+			if err != nil {
+				ec.error(err)
+			}
+			ec.capture(ev, &out, rng[0], data, truncated)
+		}
+	}
+	ec.write(ev, out.Bytes())
+}
+
+// ranges returns the ranges of the bytes to be captured for the given matches. The windows of the matches are merged
+// if they overlap, and they are limited to the given region if it is not nil.
+func (ec *EvidenceCollector) ranges(matches []EvidenceMatch, region *variables.MemoryRegion) [][2]uint64 {
+	if region != nil && ec.opts.FullRegion {
+		return [][2]uint64{{region.Base, region.Base + region.Size}}
+	}
+
+	var low, high uint64 = 0, ^uint64(0)
+	if region != nil {
+		low, high = region.Base, region.Base+region.Size
+	}
+	window := uint64(ec.opts.Window)
+	ranges := make([][2]uint64, 0, len(matches))
+	for _, m := range matches {
+		start, end := low, high
+		if m.Offset-low > window {
+			start = m.Offset - window
+		}
+		if high-m.Offset-uint64(m.Length) > window {
+			end = m.Offset + uint64(m.Length) + window
+		}
+		ranges = append(ranges, [2]uint64{start, end})
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i][0] < ranges[j][0]
+	})
+	merged := ranges[:0]
+	for _, rng := range ranges {
+		if n := len(merged); n > 0 && rng[0] <= merged[n-1][1] {
+			if rng[1] > merged[n-1][1] {
+				merged[n-1][1] = rng[1]
+			}
+			continue
+		}
+		merged = append(merged, rng)
+	}
+	return merged
+}
+
+// read reads the bytes in the given range, limited by the maximum capture size. The bytes beyond the end of the data
+// are not read.
+func (ec *EvidenceCollector) read(ra io.ReaderAt, start, end uint64) ([]byte, bool, error) {
+	size, truncated := end-start, false
+	if size > uint64(ec.opts.MaxCaptureSize) {
+		size, truncated = uint64(ec.opts.MaxCaptureSize), true
+	}
+	buf := make([]byte, size)
+	n, err := ra.ReadAt(buf, int64(start))
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return buf[:n], truncated, err
+}
+
+// capture redacts the given bytes captured, and appends them to the given data of the evidence, limited by the
+// maximum total size.
+func (ec *EvidenceCollector) capture(ev *Evidence, out *bytes.Buffer, start uint64, data []byte, truncated bool) {
+	c := EvidenceCapture{Start: start, Truncated: truncated}
+	if ec.opts.Redact != nil {
+		data = ec.opts.Redact(ev, &c, data)
+	}
+
+	ec.mu.Lock()
+	if remaining := ec.opts.MaxTotalSize - ec.total; int64(len(data)) > remaining {
+		data, c.Truncated = data[:remaining], true
+	}
+	ec.total += int64(len(data))
+	ec.mu.Unlock()
+
+	c.Size = len(data)
+	c.DataOffset = int64(out.Len())
+	out.Write(data)
+	ev.Captures = append(ev.Captures, c)
+}
+
+// write writes the given evidence and its data to the evidence directory.
+func (ec *EvidenceCollector) write(ev *Evidence, data []byte) {
+	ec.mu.Lock()
+	ec.seq++
+	name := fmt.Sprintf("%06d-%s", ec.seq, evidenceName(ev.Rule))
+	ec.mu.Unlock()
+
+	if len(data) > 0 {
+		ev.DataFile = name + ".bin"
+		if err := os.WriteFile(filepath.Join(ec.opts.Dir, ev.DataFile), data, 0o600); err != nil {
+			ec.error(err)
+			ev.DataFile = ""
+		}
+	}
+	b, err := json.MarshalIndent(ev, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(ec.opts.Dir, name+".json"), b, 0o600)
+	}
+	if err != nil {
+		ec.error(err)
+	}
+}
+
+func (ec *EvidenceCollector) error(err error) {
+	if ec.opts.OnError != nil {
+		ec.opts.OnError(fmt.Errorf("evidence: %w", err))
+	}
+}
+
+// evidenceName returns the given rule name with the characters not safe in the file names replaced.
+func evidenceName(rule string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || r == '.' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, rule)
+}
+
+// reader returns the reader of the source, and the function to close it.
+func (s *evidenceSource) reader() (io.ReaderAt, func(), error) {
+	if s.r != nil || s.file == "" {
+		return s.r, func() {}, nil
+	}
+	f, err := os.Open(s.file)
+	if err != nil {
+		return nil, func() {}, err
+	}
+	return f, func() { _ = f.Close() }, nil
+}
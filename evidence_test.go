@@ -0,0 +1,83 @@
+package gora_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+	"github.com/binalyze/gora/variables"
+)
+
+func TestEvidenceCollectorFile(t *testing.T) {
+	dir := t.TempDir()
+	ec, err := gora.NewEvidenceCollector(gora.EvidenceOptions{
+		Dir:    filepath.Join(dir, "evidence"),
+		Window: 8,
+		Redact: func(_ *gora.Evidence, c *gora.EvidenceCapture, data []byte) []byte {
+			c.Redacted = bytes.Contains(data, []byte("secret"))
+			return bytes.ReplaceAll(data, []byte("secret"), []byte("******"))
+		},
+	})
+	require.NoError(t, err)
+
+	comp := gora.NewCompiled()
+	require.NoError(t, comp.CompileString(`rule evil { strings: $a = "evil" condition: $a }`, ""))
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+	comp.SetEvidenceCollector(ec)
+
+	content := strings.Repeat("-", 100) + "secret evil payload" + strings.Repeat("-", 100)
+	path := genFile(t, dir, content)
+	require.NoError(t, comp.ScanFile(path))
+
+	var ev gora.Evidence
+	b, err := os.ReadFile(filepath.Join(dir, "evidence", "000001-evil.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &ev))
+	require.Equal(t, "evil", ev.Rule)
+	require.Equal(t, path, ev.Path)
+	require.Equal(t, []gora.EvidenceMatch{{String: "$a", Offset: 107, Length: 4}}, ev.Matches)
+	require.Len(t, ev.Captures, 1)
+	require.Equal(t, uint64(99), ev.Captures[0].Start)
+	require.True(t, ev.Captures[0].Redacted)
+
+	data, err := os.ReadFile(filepath.Join(dir, "evidence", ev.DataFile))
+	require.NoError(t, err)
+	require.Equal(t, "-****** evil payload", string(data))
+	require.EqualValues(t, len(data), ec.TotalSize())
+}
+
+func TestEvidenceCollectorMem(t *testing.T) {
+	dir := t.TempDir()
+	ec, err := gora.NewEvidenceCollector(gora.EvidenceOptions{Dir: dir, Window: 4, MaxTotalSize: 6})
+	require.NoError(t, err)
+
+	comp := gora.NewCompiled()
+	require.NoError(t, comp.CompileString(`rule evil { strings: $a = "evil" condition: $a }`, ""))
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+	comp.SetEvidenceCollector(ec)
+
+	var sctx variables.ScanContextImpl
+	sctx.SetFilePath("/a.zip!/x.js")
+	require.NoError(t, comp.ScanMem([]byte("an evil buffer"), &sctx))
+
+	var ev gora.Evidence
+	b, err := os.ReadFile(filepath.Join(dir, "000001-evil.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &ev))
+	require.Equal(t, "/a.zip!/x.js", ev.Path)
+	require.Len(t, ev.Captures, 1)
+	require.True(t, ev.Captures[0].Truncated)
+	require.Equal(t, 6, ev.Captures[0].Size)
+
+	data, err := os.ReadFile(filepath.Join(dir, ev.DataFile))
+	require.NoError(t, err)
+	require.Equal(t, "an evi", string(data))
+}
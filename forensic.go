@@ -0,0 +1,98 @@
+package gora
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// ErrPreservationUnsupported is the ForensicResult error on the platforms which the file times cannot be preserved.
+var ErrPreservationUnsupported = errors.New("file time preservation is not supported on this platform")
+
+// TimePreservation is how the times of a file were preserved by ScanFileForensic.
+type TimePreservation int
+
+const (
+	// TimesNotPreserved means the access time of the file may have been updated by the scan.
+	TimesNotPreserved TimePreservation = iota
+	// TimesNoAtime means the file was read without updating its access time, since it was opened with O_NOATIME.
+	TimesNoAtime
+	// TimesRestored means the access time of the file was restored after the scan. Note that restoring it updates the
+	// change time of the file.
+	TimesRestored
+)
+
+// String implements the fmt.Stringer interface.
+func (p TimePreservation) String() string {
+	switch p {
+	case TimesNotPreserved:
+		return "not preserved"
+	case TimesNoAtime:
+		return "noatime"
+	case TimesRestored:
+		return "restored"
+	}
+	return fmt.Sprintf("TimePreservation(%d)", int(p))
+}
+
+// ForensicResult is the result of a file scan in the forensic mode.
+type ForensicResult struct {
+	// Info is the file info taken before the scan. The variables are defined using it.
+	Info fs.FileInfo
+	// Preservation is how the times of the file were preserved.
+	Preservation TimePreservation
+	// Err is the reason if the times were not preserved.
+	Err error
+}
+
+// Preserved reports whether the scan left the access time of the file unchanged.
+func (r *ForensicResult) Preserved() bool {
+	return r.Preservation != TimesNotPreserved
+}
+
+// ScanFileForensic defines the variables using the given scan context, then scans the given file in the forensic mode
+// to preserve its access time. The file is opened with O_NOATIME on Linux. If it is not permitted, the access time taken
+// before the scan is restored with utimensat after the scan, and the modification time is left as is, so the writes
+// made during the scan are kept. Note that restoring the access time requires the ownership of the file or CAP_FOWNER
+// as O_NOATIME does, so it usually fails as well, and ForensicResult.Err reports it. The time preservation is not
+// supported on the other platforms.
+//
+// The variables are defined using the file info taken before the scan, instead of the file info of the scan context,
+// so file_accessed_time is not affected by the scan. The scan is aborted when the context of the scan context is done.
+// The result is returned even if the scan fails, unless the file cannot be opened. The errors are returned as
+// *ScanError.
+func (c *Compiled) ScanFileForensic(filename string, sctx variables.ScanContext) (*ForensicResult, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, fileScanError(err, filename)
+	}
+	f, noAtime, err := variables.OpenNoAtime(filename)
+	if err != nil {
+		return nil, fileScanError(err, filename)
+	}
+	defer f.Close() // nolint errcheck
+
+	res := &ForensicResult{Info: info}
+	if noAtime {
+		res.Preservation = TimesNoAtime
+	}
+	fsctx := &fileScanContext{ScanContext: sctx, path: sctx.FilePath(), realPath: filename, info: info}
+	err = c.DefineScannerVariables(fsctx)
+	if err == nil {
+		err = c.scanFileDescriptor(sctx.Context(), f.Fd(), &evidenceSource{path: filename, r: f})
+		var serr *ScanError
+		if errors.As(err, &serr) {
+			serr.Path = filename
+		}
+	}
+	if res.Preservation == TimesNotPreserved {
+		res.Err = restoreAccessTime(filename, info)
+		if res.Err == nil {
+			res.Preservation = TimesRestored
+		}
+	}
+	return res, err
+}
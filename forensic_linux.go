@@ -0,0 +1,29 @@
+//go:build linux
+// +build linux
+
+package gora
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// restoreAccessTime restores the access time of the given file from the given file info. The modification time is
+// not changed. It requires the same privilege as O_NOATIME.
+func restoreAccessTime(name string, info fs.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.New("no stat of the file")
+	}
+	ts := []unix.Timespec{
+		unix.NsecToTimespec(st.Atim.Nano()),
+		{Nsec: unix.UTIME_OMIT},
+	}
+	if err := unix.UtimesNanoAt(unix.AT_FDCWD, name, ts, 0); err != nil {
+		return &fs.PathError{Op: "utimensat", Path: name, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+//go:build linux
+// +build linux
+
+package gora
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/djherbis/times"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreAccessTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evidence")
+	require.NoError(t, os.WriteFile(path, []byte("some evidence"), 0o644))
+	atime := time.Unix(946684800, 0)
+	require.NoError(t, os.Chtimes(path, atime, atime))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	// the file is read and written during the scan.
+	_, err = os.ReadFile(path)
+	require.NoError(t, err)
+	mtime := time.Unix(1700000000, 0)
+	require.NoError(t, os.Chtimes(path, time.Now(), mtime))
+
+	require.NoError(t, restoreAccessTime(path, info))
+	ts, err := times.Stat(path)
+	require.NoError(t, err)
+	require.True(t, atime.Equal(ts.AccessTime()))
+	require.True(t, mtime.Equal(ts.ModTime()))
+}
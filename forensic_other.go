@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package gora
+
+import "io/fs"
+
+// restoreAccessTime is not supported on this platform.
+func restoreAccessTime(string, fs.FileInfo) error {
+	return ErrPreservationUnsupported
+}
@@ -0,0 +1,93 @@
+package gora_test
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/djherbis/times"
+	"github.com/hillu/go-yara/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+	"github.com/binalyze/gora/variables"
+)
+
+func TestScanFileForensic(t *testing.T) {
+	comp := gora.NewCompiled()
+	err := comp.CompileString(`
+	rule x {
+		strings:
+			$a = "evidence"
+		condition:
+			file_accessed_time < 1000000000 and $a
+	}`, "")
+	require.NoError(t, err)
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	path := genFile(t, t.TempDir(), "some evidence")
+	atime := time.Unix(946684800, 0)
+	require.NoError(t, os.Chtimes(path, atime, atime))
+
+	var sctx variables.ScanContextImpl
+	sctx.SetInFileSystem(true)
+	sctx.SetFilePath(path)
+
+	var matches yara.MatchRules
+	comp.SetCallback(&matches)
+	res, err := comp.ScanFileForensic(path, &sctx)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.NotNil(t, res.Info)
+
+	if runtime.GOOS != "linux" {
+		require.False(t, res.Preserved())
+		require.ErrorIs(t, res.Err, gora.ErrPreservationUnsupported)
+		return
+	}
+	require.True(t, res.Preserved())
+	require.NoError(t, res.Err)
+
+	ts, err := times.Stat(path)
+	require.NoError(t, err)
+	require.True(t, atime.Equal(ts.AccessTime()))
+}
+
+func TestScanFileForensicNotExist(t *testing.T) {
+	comp := gora.NewCompiled()
+	require.NoError(t, comp.CompileString(`rule x { condition: true }`, ""))
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	var sctx variables.ScanContextImpl
+	res, err := comp.ScanFileForensic(t.TempDir()+"/missing", &sctx)
+	require.Nil(t, res)
+	require.ErrorIs(t, err, gora.ErrVanished)
+}
+
+func TestScanFileForensicNotOwned(t *testing.T) {
+	if runtime.GOOS != "linux" || os.Geteuid() == 0 {
+		t.Skip("requires a file not owned by the user running the test")
+	}
+	const path = "/etc/passwd"
+	if _, err := os.Stat(path); err != nil {
+		t.Skip(err)
+	}
+
+	comp := gora.NewCompiled()
+	require.NoError(t, comp.CompileString(`rule x { condition: true }`, ""))
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	// O_NOATIME is not permitted, and restoring the access time fails for the same reason.
+	var sctx variables.ScanContextImpl
+	var matches yara.MatchRules
+	comp.SetCallback(&matches)
+	res, err := comp.ScanFileForensic(path, &sctx)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Equal(t, gora.TimesNotPreserved, res.Preservation)
+	require.ErrorIs(t, res.Err, os.ErrPermission)
+}
@@ -0,0 +1,127 @@
+package gora
+
+import (
+	"io/fs"
+	"path/filepath"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// ScanFunc is called after each scan of the methods scanning multiple targets, such as ScanFS and ScanArchive, with
+// the path of the target scanned and the scan error if any. It is also called with the errors of reaching the
+// targets, such as reading a directory. Returning an error stops the scanning, and the error is returned.
+type ScanFunc func(path string, err error) error
+
+// FSScanOptions holds the settings of ScanFS.
+type FSScanOptions struct {
+	ScanOptions
+	// Prefix is joined with the paths in the file system to set file_path, such as the mount point of an image
+	// scanned through os.DirFS. The slash separated paths of the file system are used as is if it is empty. The file
+	// attributes, such as file_immutable and file_fs_type, are read from the paths joined with it, so they are
+	// defined with their default values if it is empty.
+	Prefix string
+	// SkipFSTypes are the file system types of the directories skipped, such as "proc" or "nfs", as in the mount
+	// table. The mounts of the directories are looked up with their paths joined with Prefix, so it is to scan
+	// os.DirFS on Linux. ScanFS fails if the mount table cannot be read.
+	SkipFSTypes []string
+	// Mounts is set to the scan contexts as the mount table of the file system variables, such as file_fs_type, and
+	// it is used to skip SkipFSTypes. A mount table read once per call is used if it is nil.
+	Mounts *variables.MountTable
+}
+
+// ScanFS walks the given file system from the given root using fs.WalkDir, and scans the regular files with their
+// variables defined from their fs.FileInfo. The files are read using ScanReader, so the chunked mode of the reader
+// options applies to them. It is to scan the file systems such as embed.FS, zip.Reader and os.DirFS.
+//
+// The given function is called after each file scanned to report the results. If it is nil, ScanFS stops at the
+// first error and returns it.
+func (c *Compiled) ScanFS(fsys fs.FS, root string, opts FSScanOptions, fn ScanFunc) error {
+	ctx := opts.ctx()
+	if fn == nil {
+		fn = returnScanError
+	}
+
+	mounts := opts.Mounts
+	if mounts == nil && len(opts.SkipFSTypes) > 0 {
+		mounts = &variables.MountTable{}
+	}
+	if len(opts.SkipFSTypes) > 0 {
+		if _, err := mounts.Mounts(); err != nil {
+			return err
+		}
+	}
+
+	var sctx variables.ScanContextImpl
+	return fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		filePath := fsFilePath(opts.Prefix, p)
+		if err != nil {
+			return fn(filePath, err)
+		}
+		if d.IsDir() && len(opts.SkipFSTypes) > 0 {
+			m, err := mounts.Lookup(filePath)
+			if err != nil {
+				return fn(filePath, err)
+			}
+			if m != nil && containsString(opts.SkipFSTypes, m.FSType) {
+				return fs.SkipDir
+			}
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if err = ctx.Err(); err != nil {
+			return &CancelledError{Err: err}
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fn(filePath, err)
+		}
+
+		opts.resetScanContext(&sctx)
+		sctx.SetMountTable(mounts)
+		sctx.SetInFileSystem(true)
+		sctx.SetFilePath(filePath)
+		sctx.SetRealPath(fsRealPath(opts.Prefix, filePath))
+		sctx.SetFileInfo(info)
+
+		f, err := fsys.Open(p)
+		if err != nil {
+			return fn(filePath, &ScanError{Kind: classify(err), Path: filePath, Err: err})
+		}
+		err = c.ScanReader(f, info.Size(), &sctx)
+		_ = f.Close()
+		return fn(filePath, err)
+	})
+}
+
+// fsFilePath returns the file_path of the given slash separated path of a file system.
+func fsFilePath(prefix, p string) string {
+	if prefix == "" {
+		return p
+	}
+	return filepath.Join(prefix, filepath.FromSlash(p))
+}
+
+// fsRealPath returns the path the file of the given file_path is read from on the host. The paths of a file system
+// scanned without a prefix are relative to the file system, so its files cannot be read from the host.
+func fsRealPath(prefix, filePath string) string {
+	if prefix == "" {
+		return ""
+	}
+	return filePath
+}
+
+func returnScanError(_ string, err error) error {
+	return err
+}
+
+// containsString reports whether the given strings contain the given string.
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package gora_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/hillu/go-yara/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+	"github.com/binalyze/gora/variables"
+)
+
+func TestScanFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"docs/x.js":       {Data: []byte("a test"), ModTime: time.Now()},
+		"docs/readme.txt": {Data: []byte("a test")},
+		"lib/y.js":        {Data: []byte("no match")},
+	}
+
+	comp := gora.NewCompiled()
+	err := comp.CompileString(`
+	rule js {
+		strings:
+			$a = "test"
+		condition:
+			file_extension == "js" and file_modified_time > 0 and $a
+	}`, "")
+	require.NoError(t, err)
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	var matches yara.MatchRules
+	comp.SetCallback(&matches)
+
+	prefix := filepath.Join(t.TempDir(), "image")
+	matched := map[string]bool{}
+	err = comp.ScanFS(fsys, ".", gora.FSScanOptions{Prefix: prefix}, func(p string, err error) error {
+		require.NoError(t, err)
+		matched[p] = len(matches) > 0
+		matches = nil
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{
+		filepath.Join(prefix, "docs", "x.js"):       true,
+		filepath.Join(prefix, "docs", "readme.txt"): false,
+		filepath.Join(prefix, "lib", "y.js"):        false,
+	}, matched)
+
+	matched = map[string]bool{}
+	err = comp.ScanFS(fsys, "docs", gora.FSScanOptions{}, func(p string, err error) error {
+		matched[p] = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"docs/x.js": true, "docs/readme.txt": true}, matched)
+}
+
+func TestScanFSSkipFSTypes(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("mount table is supported on linux only")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "share"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a test"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "share", "b.txt"), []byte("a test"), 0o644))
+	mountInfo := filepath.Join(t.TempDir(), "mountinfo")
+	require.NoError(t, os.WriteFile(mountInfo, []byte("1 0 8:1 / / rw - ext4 /dev/sda1 rw\n"+
+		"2 1 0:50 / "+filepath.Join(dir, "share")+" rw - nfs4 server:/export rw\n"), 0o644))
+
+	vars, err := variables.Select("file_*")
+	require.NoError(t, err)
+	comp := gora.NewCompiled().SetVariables(vars)
+	err = comp.CompileString(`
+	rule local {
+		strings:
+			$a = "test"
+		condition:
+			file_fs_type == "ext4" and not file_on_network_fs and $a
+	}`, "")
+	require.NoError(t, err)
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	var matches yara.MatchRules
+	comp.SetCallback(&matches)
+
+	opts := gora.FSScanOptions{Prefix: dir, Mounts: &variables.MountTable{Path: mountInfo}}
+	matched := map[string]bool{}
+	scan := func(p string, err error) error {
+		require.NoError(t, err)
+		matched[p] = len(matches) > 0
+		matches = nil
+		return nil
+	}
+	require.NoError(t, comp.ScanFS(os.DirFS(dir), ".", opts, scan))
+	require.Equal(t, map[string]bool{
+		filepath.Join(dir, "a.txt"):          true,
+		filepath.Join(dir, "share", "b.txt"): false,
+	}, matched)
+
+	matched = map[string]bool{}
+	opts.SkipFSTypes = []string{"nfs", "nfs4"}
+	require.NoError(t, comp.ScanFS(os.DirFS(dir), ".", opts, scan))
+	require.Equal(t, map[string]bool{filepath.Join(dir, "a.txt"): true}, matched)
+}
@@ -1,11 +1,14 @@
 package gora
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/hillu/go-yara/v4"
 
@@ -16,9 +19,24 @@ var ErrAlreadyCompiled = errors.New("already compiled")
 
 // Compiled holds the compiled rules and its associated external variables.
 type Compiled struct {
-	vars    *variables.Variables
-	rules   *yara.Rules
-	scanner *yara.Scanner
+	vars     *variables.Variables
+	rules    *yara.Rules
+	scanner  *yara.Scanner
+	callback yara.ScanCallback
+	evidence *EvidenceCollector
+	options  *ScannerOptions
+	reader   ReaderOptions
+	timeout  time.Duration
+	limit    ScanLimit
+	varList  []variables.VariableType
+	prune    bool
+	pruned   []PrunedRule
+
+	prefilterEnabled   bool
+	prefilter          *variables.Prefilter
+	prefilterEvaluated atomic.Uint64
+	prefilterSkipped   atomic.Uint64
+	skip               bool
 }
 
 func NewCompiled() *Compiled {
@@ -40,6 +58,12 @@ func (c *Compiled) CompileString(rule, namespace string) error {
 
 // CompileStrings compiles the YARA rules.
 func (c *Compiled) CompileStrings(ruleNs []RuleNamespace) error {
+	return c.compileStrings(ruleNs, nil, nil)
+}
+
+// compileStrings compiles the given rules. The given setup function is called with the compiler before adding the
+// rules, and the given add function is called with the index of each rule before adding it, if they are not nil.
+func (c *Compiled) compileStrings(ruleNs []RuleNamespace, setup func(*yara.Compiler), add func(int)) error {
 	if c.rules != nil {
 		return ErrAlreadyCompiled
 	}
@@ -50,16 +74,31 @@ func (c *Compiled) CompileStrings(ruleNs []RuleNamespace) error {
 	}
 	defer compiler.Destroy()
 
-	vars := variables.List()
-	c.initVariables(vars)
+	if setup != nil {
+		setup(compiler)
+	}
+
+	c.initVariables(c.variableList())
 
 	if err = c.vars.DefineCompilerVariables(compiler); err != nil {
 		err = fmt.Errorf("compiler define variable error: %w", err)
 		return compilerError(compiler, err)
 	}
 
-	for _, rule := range ruleNs {
-		err = compiler.AddString(rule.Rule, rule.Namespace)
+	pruned, err := c.preprocessRules(ruleNs)
+	if err != nil {
+		return compilerError(compiler, err)
+	}
+
+	for i, rule := range ruleNs {
+		src, ok := pruned[i]
+		if !ok {
+			src = rule.Rule
+		}
+		if add != nil {
+			add(i)
+		}
+		err = compiler.AddString(src, rule.Namespace)
 		if err != nil {
 			err = fmt.Errorf("compiler add rule error: %w", err)
 			return compilerError(compiler, err)
@@ -168,8 +207,7 @@ func (c *Compiled) CompileFiles(filenameNS bool, paths ...string) error {
 		files = append(files, f)
 	}
 
-	vars := variables.List()
-	c.initVariables(vars)
+	c.initVariables(c.variableList())
 
 	err = c.vars.DefineCompilerVariables(compiler)
 	if err != nil {
@@ -177,10 +215,21 @@ func (c *Compiled) CompileFiles(filenameNS bool, paths ...string) error {
 		return compilerError(compiler, err)
 	}
 
-	c.rules, err = compileFiles(compiler, files, filenameNS)
+	c.rules, err = c.compileFiles(compiler, files, filenameNS)
 	return err
 }
 
+// SetVariables sets the external variables to be defined for the compiler and the scanner. It must be called before
+// compiling the rules. The variables of variables.Defaults are defined by default. Use variables.Select or
+// variables.Preset to select the variables by name, including the opt-in ones.
+//
+// The rules referring to a variable which is not set cannot be compiled.
+func (c *Compiled) SetVariables(vars []variables.VariableType) *Compiled {
+	c.varList = make([]variables.VariableType, len(vars))
+	copy(c.varList, vars)
+	return c
+}
+
 func (c *Compiled) Variables() *variables.Variables {
 	return c.vars
 }
@@ -189,8 +238,9 @@ func (c *Compiled) Rules() *yara.Rules {
 	return c.rules
 }
 
+// CreateScanner creates the scanner of the compiled rules with the scanner options applied. See SetScannerOptions.
 func (c *Compiled) CreateScanner() error {
-	s, err := yara.NewScanner(c.rules)
+	s, err := c.NewScanner()
 	if err != nil {
 		return err
 	}
@@ -198,29 +248,52 @@ func (c *Compiled) CreateScanner() error {
 	return nil
 }
 
+// Scanner returns the scanner created by CreateScanner. The callback set on it is used by the scans of Compiled, like
+// the one set by SetCallback. Set the timeout using SetScannerOptions, since the timeout set on it is replaced after
+// the scans with a deadline.
 func (c *Compiled) Scanner() *yara.Scanner {
 	return c.scanner
 }
 
 func (c *Compiled) DefineScannerVariables(sctx variables.ScanContext) error {
-	return c.vars.DefineScannerVariables(sctx, c.scanner)
+	values := c.vars.NewValues(sctx)
+	c.skip = c.prefilterSkip(values)
+	if c.skip {
+		return nil
+	}
+	return cancelledError(c.vars.DefineScannerValues(values, c.scanner))
+}
+
+// SetValuerTimeout sets the maximum duration of each variable Valuer call in DefineScannerVariables. See
+// variables.Variables.SetValuerTimeout.
+func (c *Compiled) SetValuerTimeout(d time.Duration) *Compiled {
+	c.vars.SetValuerTimeout(d)
+	return c
 }
 
 func (c *Compiled) SetCallback(cb yara.ScanCallback) *Compiled {
+	c.callback = cb
 	c.scanner.SetCallback(cb)
 	return c
 }
 
+// SetEvidenceCollector sets the collector of the evidence of the matching rules, or removes it if it is nil. See
+// EvidenceCollector.
+func (c *Compiled) SetEvidenceCollector(ec *EvidenceCollector) *Compiled {
+	c.evidence = ec
+	return c
+}
+
 func (c *Compiled) ScanFileDescriptor(fd uintptr) error {
-	return c.scanner.ScanFileDescriptor(fd)
+	return c.ScanFileDescriptorContext(context.Background(), fd)
 }
 
 func (c *Compiled) ScanFile(filename string) error {
-	return c.scanner.ScanFile(filename)
+	return c.ScanFileContext(context.Background(), filename)
 }
 
 func (c *Compiled) ScanProc(pid int) error {
-	return c.scanner.ScanProc(pid)
+	return c.ScanProcContext(context.Background(), pid)
 }
 
 func (c *Compiled) Destroy() {
@@ -234,16 +307,21 @@ func (c *Compiled) Destroy() {
 	}
 }
 
-func compileFiles(compiler *yara.Compiler, files []*os.File, filenameNS bool) (*yara.Rules, error) {
-	for _, file := range files {
+func (c *Compiled) compileFiles(compiler *yara.Compiler, files []*os.File, filenameNS bool) (*yara.Rules, error) {
+	pruned, err := c.preprocessFiles(files, filenameNS)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, file := range files {
 		file := file
+		namespace := fileNamespace(file, filenameNS)
 
-		var namespace string
-		if filenameNS {
-			namespace = filepath.Base(file.Name())
+		if src, ok := pruned[i]; ok {
+			err = compiler.AddString(src, namespace)
+		} else {
+			err = compiler.AddFile(file, namespace)
 		}
-
-		err := compiler.AddFile(file, namespace)
 		if err != nil {
 			err = fmt.Errorf("compiler add rule error: %w", err)
 			return nil, compilerError(compiler, err)
@@ -258,6 +336,13 @@ func compileFiles(compiler *yara.Compiler, files []*os.File, filenameNS bool) (*
 	return rules, nil
 }
 
+func fileNamespace(file *os.File, filenameNS bool) string {
+	if filenameNS {
+		return filepath.Base(file.Name())
+	}
+	return ""
+}
+
 func compilerError(c *yara.Compiler, err error) error {
 	if c != nil && len(c.Errors) > 1 {
 		err = fmt.Errorf("%w more: %s", err, mergeCompilerErrors(c.Errors[1:]))
@@ -273,6 +358,13 @@ func mergeCompilerErrors(cm []yara.CompilerMessage) string {
 	return strings.Join(msgs, " ; ")
 }
 
+func (c *Compiled) variableList() []variables.VariableType {
+	if c.varList == nil {
+		return variables.Defaults()
+	}
+	return c.varList
+}
+
 func (c *Compiled) initVariables(vars []variables.VariableType) {
 	c.vars.InitVariables(vars)
 }
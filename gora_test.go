@@ -72,7 +72,7 @@ func TestBuildRuleWithAllVars(t *testing.T) {
 
 	rs := fmt.Sprintf(ruleAllVarsTmpl, sb.String())
 
-	comp := gora.NewCompiled()
+	comp := gora.NewCompiled().SetVariables(vars)
 	path := genFile(t, tempDir, rs)
 	err := comp.CompileFiles(true, path)
 	require.NoError(t, err)
@@ -110,3 +110,27 @@ func genFile(t *testing.T, dir, rulestr string) string {
 	require.NoError(t, f.Close())
 	return p
 }
+
+func TestCompileSelectedVariables(t *testing.T) {
+	vars, err := variables.Select(variables.PresetFile)
+	require.NoError(t, err)
+
+	comp := gora.NewCompiled().SetVariables(vars)
+	err = comp.CompileString(`rule x { condition: file_name == "a" }`, "")
+	require.NoError(t, err)
+	require.Equal(t, vars, comp.Variables().Variables())
+
+	comp = gora.NewCompiled().SetVariables(vars)
+	err = comp.CompileString(`rule x { condition: process_name == "a" }`, "")
+	require.Error(t, err)
+
+	// the opt-in variables are defined only if they are selected.
+	comp = gora.NewCompiled()
+	err = comp.CompileString(`rule x { condition: file_fs_type == "ext4" }`, "")
+	require.Error(t, err)
+	require.Equal(t, variables.Defaults(), comp.Variables().Variables())
+
+	comp = gora.NewCompiled().SetVariables(vars)
+	err = comp.CompileString(`rule x { condition: file_fs_type == "ext4" }`, "")
+	require.NoError(t, err)
+}
@@ -0,0 +1,543 @@
+package gora
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// The whiteout file name prefixes of the image layers. See the OCI image layer specification.
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// maxImageIndexDepth limits following the nested image indexes of an OCI layout.
+const maxImageIndexDepth = 4
+
+// ImageScanOptions holds the settings of ScanImage.
+type ImageScanOptions struct {
+	ScanOptions
+	// TempDir is the directory of the temporary files the compressed layers are decompressed into. os.TempDir is used
+	// if it is empty.
+	TempDir string
+}
+
+// ImageFile is a file of a container image scanned by ScanImage.
+type ImageFile struct {
+	// Path is the absolute slash separated path of the file in the image, which is set to file_path.
+	Path string
+	// Layer is the digest of the layer the file is read from, such as "sha256:...".
+	Layer string
+	// Info is the file info synthesized from the layer header.
+	Info fs.FileInfo
+}
+
+// ImageScanFunc is called by ScanImage after each file scanned with the file and the scan error if any. It is also
+// called with the errors of reading the layers, with the layer digest set only. Returning an error stops ScanImage,
+// and the error is returned.
+type ImageScanFunc func(file ImageFile, err error) error
+
+// ScanImage scans the files of a container image without running it. The image is either a tar file exported by
+// "docker save" or a directory of an OCI image layout. The layers of the image are applied in order, including the
+// whiteout files, into a virtual merged tree, and each file of the merged tree is scanned once with its path in the
+// image as file_path. Only the regular files are scanned, so the hard links are scanned as their targets.
+//
+// The compressed layers are decompressed once while merging the layers, into temporary files in
+// ImageScanOptions.TempDir, which are removed after the files of their layers are scanned. Therefore, the directory
+// must have free space for the decompressed layers of the image.
+//
+// The first image of the image manifest or the OCI image index is scanned. The given function is called after each
+// file scanned to report the results. If it is nil, ScanImage stops at the first error and returns it.
+func (c *Compiled) ScanImage(image string, opts ImageScanOptions, fn ImageScanFunc) error {
+	ctx := opts.ctx()
+	if fn == nil {
+		fn = func(_ ImageFile, err error) error {
+			return err
+		}
+	}
+
+	src, err := openImageSource(image)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	layers, err := readImageLayers(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for i := range layers {
+			layers[i].removeSpool()
+		}
+	}()
+	tree, err := mergeImageLayers(src, layers, opts.TempDir)
+	if err != nil {
+		return err
+	}
+
+	var sctx variables.ScanContextImpl
+	for i, layer := range layers {
+		err = walkImageLayer(src, layer, nil, nil, func(tr *tar.Reader, hdr *tar.Header, index int) error {
+			name := imagePath(hdr.Name)
+			if owner, ok := tree[name]; !ok || owner != (imageOwner{layer: i, index: index}) {
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return &CancelledError{Err: err}
+			}
+
+			file := ImageFile{
+				Path:  name,
+				Layer: layer.digest,
+				Info: &variables.VirtualFileInfo{
+					FileName:     path.Base(name),
+					FileSize:     hdr.Size,
+					FileMode:     hdr.FileInfo().Mode(),
+					ModifiedTime: hdr.ModTime,
+					AccessedTime: hdr.AccessTime,
+					ChangedTime:  hdr.ChangeTime,
+				},
+			}
+			opts.resetScanContext(&sctx)
+			sctx.SetInFileSystem(true)
+			sctx.SetFilePath(file.Path)
+			sctx.SetRealPath("")
+			sctx.SetFileInfo(file.Info)
+			if err := fn(file, c.ScanReader(tr, hdr.Size, &sctx)); err != nil {
+				return &imageStopError{err: err}
+			}
+			return nil
+		})
+		layers[i].removeSpool()
+
+		var stop *imageStopError
+		if errors.As(err, &stop) {
+			return stop.err
+		}
+		var cerr *CancelledError
+		if errors.As(err, &cerr) {
+			return err
+		}
+		if err != nil {
+			if err = fn(ImageFile{Layer: layer.digest}, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// imageStopError wraps the errors returned from the ImageScanFunc to stop ScanImage.
+type imageStopError struct {
+	err error
+}
+
+func (e *imageStopError) Error() string {
+	return e.err.Error()
+}
+
+// imageLayer is a layer of an image.
+type imageLayer struct {
+	// name is the path of the layer in the image source.
+	name string
+	// digest is the digest of the layer, which is computed while merging the layers if it is not in the manifest.
+	digest string
+	// spool is the temporary file the layer is decompressed into while merging the layers if it is compressed, which
+	// is read instead of decompressing the layer again.
+	spool *os.File
+}
+
+// removeSpool closes and removes the temporary file of the decompressed layer if it exists.
+func (l *imageLayer) removeSpool() {
+	if l.spool != nil {
+		_ = l.spool.Close()
+		_ = os.Remove(l.spool.Name())
+		l.spool = nil
+	}
+}
+
+// imageOwner identifies the entry of a file in the merged tree, by the index of its layer and its index in the layer.
+type imageOwner struct {
+	layer int
+	index int
+}
+
+// imageSource reads the files of an image, either from a directory or a tar file.
+type imageSource interface {
+	Open(name string) (io.ReadCloser, error)
+	Close() error
+}
+
+func openImageSource(image string) (imageSource, error) {
+	info, err := os.Stat(image)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return dirImageSource(image), nil
+	}
+	return openTarImageSource(image)
+}
+
+// dirImageSource is an image source of an OCI image layout directory.
+type dirImageSource string
+
+func (s dirImageSource) Open(name string) (io.ReadCloser, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return os.Open(filepath.Join(string(s), filepath.FromSlash(name)))
+}
+
+func (s dirImageSource) Close() error {
+	return nil
+}
+
+// tarImageSource is an image source of a tar file. The files are read from their offsets in the tar file indexed
+// once, since the layers are tar files in the tar file.
+type tarImageSource struct {
+	f       *os.File
+	entries map[string]*io.SectionReader
+}
+
+func openTarImageSource(image string) (*tarImageSource, error) {
+	f, err := os.Open(image)
+	if err != nil {
+		return nil, err
+	}
+	s := &tarImageSource{f: f, entries: make(map[string]*io.SectionReader)}
+
+	cr := &countingReader{r: bufio.NewReader(f)}
+	tr := tar.NewReader(cr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("image tar: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			// the tar reader reads the headers only, so the data starts at the current offset.
+			s.entries[path.Clean(hdr.Name)] = io.NewSectionReader(f, cr.n, hdr.Size)
+		}
+	}
+	return s, nil
+}
+
+func (s *tarImageSource) Open(name string) (io.ReadCloser, error) {
+	sr, ok := s.entries[path.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return io.NopCloser(io.NewSectionReader(sr, 0, sr.Size())), nil
+}
+
+func (s *tarImageSource) Close() error {
+	return s.f.Close()
+}
+
+// countingReader counts the bytes read, which is the offset of the tar entries when it is read by the tar reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// readImageLayers returns the layers of the first image in the given image source, in the order they are applied.
+// The docker save manifest is read if it exists, otherwise the OCI image index.
+func readImageLayers(src imageSource) ([]imageLayer, error) {
+	var manifest []struct {
+		Layers []string
+	}
+	err := readImageJSON(src, "manifest.json", &manifest)
+	if err == nil {
+		if len(manifest) == 0 {
+			return nil, errors.New("image manifest: no image")
+		}
+		layers := make([]imageLayer, 0, len(manifest[0].Layers))
+		for _, name := range manifest[0].Layers {
+			layers = append(layers, imageLayer{name: name, digest: blobDigest(name)})
+		}
+		return layers, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	type descriptor struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	}
+	var index struct {
+		Manifests []descriptor `json:"manifests"`
+		Layers    []descriptor `json:"layers"`
+	}
+	if err = readImageJSON(src, "index.json", &index); err != nil {
+		return nil, err
+	}
+	for depth := 0; len(index.Layers) == 0; depth++ {
+		if len(index.Manifests) == 0 || depth >= maxImageIndexDepth {
+			return nil, errors.New("image index: no image manifest")
+		}
+		name, err := blobPath(index.Manifests[0].Digest)
+		if err != nil {
+			return nil, err
+		}
+		index.Manifests = nil
+		if err = readImageJSON(src, name, &index); err != nil {
+			return nil, err
+		}
+	}
+
+	layers := make([]imageLayer, 0, len(index.Layers))
+	for _, l := range index.Layers {
+		name, err := blobPath(l.Digest)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, imageLayer{name: name, digest: l.Digest})
+	}
+	return layers, nil
+}
+
+func readImageJSON(src imageSource, name string, v interface{}) error {
+	rc, err := src.Open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err = json.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("image %s: %w", name, err)
+	}
+	return nil
+}
+
+// blobPath returns the path of the blob of the given digest in an OCI image layout.
+func blobPath(digest string) (string, error) {
+	alg, hexDigest, ok := strings.Cut(digest, ":")
+	if !ok || alg == "" || hexDigest == "" || strings.ContainsAny(digest, "/\\") {
+		return "", fmt.Errorf("invalid image digest '%s'", digest)
+	}
+	return "blobs/" + alg + "/" + hexDigest, nil
+}
+
+// blobDigest returns the digest of the given blob path of an OCI image layout, or an empty string if it is not a blob
+// path.
+func blobDigest(name string) string {
+	parts := strings.Split(path.Clean(name), "/")
+	if len(parts) != 3 || parts[0] != "blobs" {
+		return ""
+	}
+	return parts[1] + ":" + parts[2]
+}
+
+// mergeImageLayers applies the given layers in order, and returns the owners of the files in the merged tree by
+// their paths in the image. The digests of the layers missing them are computed, and the compressed layers are
+// decompressed into temporary files in the given directory.
+func mergeImageLayers(src imageSource, layers []imageLayer, tempDir string) (map[string]imageOwner, error) {
+	tree := newImageTree()
+	for i := range layers {
+		layer := &layers[i]
+		var h hash.Hash
+		if layer.digest == "" {
+			h = sha256.New()
+		}
+		spool := func() (io.Writer, error) {
+			f, err := os.CreateTemp(tempDir, "gora-*")
+			if err != nil {
+				return nil, err
+			}
+			layer.spool = f
+			return f, nil
+		}
+		err := walkImageLayer(src, *layer, h, spool, func(_ *tar.Reader, hdr *tar.Header, index int) error {
+			tree.apply(hdr, imageOwner{layer: i, index: index})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("image layer %s: %w", layer.name, err)
+		}
+		if h != nil {
+			layer.digest = "sha256:" + hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	return tree.files, nil
+}
+
+// imageTree is the merged tree of the image layers. The paths are indexed by their parent directories, so the files
+// under a directory hidden by an upper layer are removed without visiting the whole tree.
+type imageTree struct {
+	// files are the owners of the regular files by their paths.
+	files map[string]imageOwner
+	// children are the paths of the entries in the directories by the directory paths. The entries of the removed
+	// files may remain, they are dropped when their directories are removed.
+	children map[string]map[string]struct{}
+}
+
+func newImageTree() *imageTree {
+	return &imageTree{
+		files:    make(map[string]imageOwner),
+		children: make(map[string]map[string]struct{}),
+	}
+}
+
+// apply applies the given layer entry to the merged tree.
+func (t *imageTree) apply(hdr *tar.Header, owner imageOwner) {
+	name := imagePath(hdr.Name)
+	dir, base := path.Split(name)
+
+	switch {
+	case base == whiteoutOpaque:
+		t.removeTree(path.Clean(dir), owner.layer)
+	case strings.HasPrefix(base, whiteoutPrefix):
+		target := path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+		delete(t.files, target)
+		t.removeTree(target, owner.layer)
+	case hdr.Typeflag == tar.TypeDir:
+		delete(t.files, name)
+	default:
+		t.removeTree(name, owner.layer)
+		if hdr.Typeflag == tar.TypeReg {
+			t.add(name, owner)
+		} else {
+			delete(t.files, name)
+		}
+	}
+}
+
+// add adds the given regular file and links it to its parent directories.
+func (t *imageTree) add(name string, owner imageOwner) {
+	t.files[name] = owner
+	for name != "/" {
+		dir := path.Dir(name)
+		entries, ok := t.children[dir]
+		if !ok {
+			entries = make(map[string]struct{})
+			t.children[dir] = entries
+		}
+		if _, ok = entries[name]; ok {
+			// The parent directories are already linked.
+			return
+		}
+		entries[name] = struct{}{}
+		name = dir
+	}
+}
+
+// removeTree removes the files under the given directory from the lower layers than the given layer.
+func (t *imageTree) removeTree(dir string, layer int) {
+	entries, ok := t.children[dir]
+	if !ok {
+		return
+	}
+	for name := range entries {
+		if owner, ok := t.files[name]; ok && owner.layer < layer {
+			delete(t.files, name)
+		}
+		t.removeTree(name, layer)
+		if _, ok := t.files[name]; !ok && len(t.children[name]) == 0 {
+			delete(entries, name)
+		}
+	}
+	if len(entries) == 0 {
+		delete(t.children, dir)
+	}
+}
+
+// walkImageLayer calls the given function for each entry of the given layer with the index of the entry in the layer.
+// The layer is read from its temporary file if it is decompressed already. Otherwise, it is decompressed if it is gzip
+// compressed, into the writer returned by the given spool function if it is not nil. The layer blob is written to the
+// given hash if it is not nil.
+func walkImageLayer(src imageSource, layer imageLayer, h hash.Hash, spool func() (io.Writer, error),
+	fn func(tr *tar.Reader, hdr *tar.Header, index int) error) error {
+	if layer.spool != nil {
+		// the tar reader seeks the file to skip the entries not read.
+		if _, err := layer.spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return walkImageTar(layer.spool, fn)
+	}
+
+	rc, err := src.Open(layer.name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if h != nil {
+		r = io.TeeReader(r, h)
+	}
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+		if spool != nil {
+			w, err := spool()
+			if err != nil {
+				return err
+			}
+			r = io.TeeReader(gr, w)
+		}
+	} else {
+		r = br
+	}
+
+	if err = walkImageTar(r, fn); err != nil {
+		return err
+	}
+	if h != nil {
+		// hash the padding after the end of the archive as well.
+		_, err = io.Copy(io.Discard, br)
+	}
+	return err
+}
+
+// walkImageTar calls the given function for each entry of the given tar stream with the index of the entry.
+func walkImageTar(r io.Reader, fn func(tr *tar.Reader, hdr *tar.Header, index int) error) error {
+	tr := tar.NewReader(r)
+	for index := 0; ; index++ {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(tr, hdr, index); err != nil {
+			return err
+		}
+	}
+}
+
+// imagePath returns the absolute path in the image of the given layer entry name.
+func imagePath(name string) string {
+	return path.Clean("/" + name)
+}
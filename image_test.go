@@ -0,0 +1,172 @@
+package gora_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hillu/go-yara/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+)
+
+type imageEntry struct {
+	name    string
+	content string
+	dir     bool
+}
+
+func buildLayer(t *testing.T, compress bool, entries ...imageEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var gw *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if compress {
+		gw = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gw)
+	}
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		if e.dir {
+			hdr.Typeflag, hdr.Size, hdr.Mode = tar.TypeDir, 0, 0o755
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	if gw != nil {
+		require.NoError(t, gw.Close())
+	}
+	return buf.Bytes()
+}
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func testImageLayers(t *testing.T) [][]byte {
+	return [][]byte{
+		buildLayer(t, false,
+			imageEntry{name: "etc/", dir: true},
+			imageEntry{name: "etc/passwd", content: "root test"},
+			imageEntry{name: "etc/deleted", content: "test"},
+			imageEntry{name: "opt/app/old.js", content: "test"},
+			imageEntry{name: "bin/tool", content: "old test"},
+			imageEntry{name: "var/data/old", content: "test"},
+			imageEntry{name: "usr/share/doc/old", content: "test"},
+		),
+		buildLayer(t, true,
+			imageEntry{name: "etc/.wh.deleted"},
+			imageEntry{name: "opt/app/.wh..wh..opq"},
+			imageEntry{name: "opt/app/new.js", content: "test"},
+			imageEntry{name: "bin/tool", content: "new test"},
+			imageEntry{name: "var/data", content: "file"},
+			imageEntry{name: "usr/share/.wh.doc"},
+		),
+	}
+}
+
+func writeDockerSave(t *testing.T, dir string, layers [][]byte) string {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	add := func(name string, b []byte) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(b))}))
+		_, err := tw.Write(b)
+		require.NoError(t, err)
+	}
+	var names []string
+	for i, l := range layers {
+		name := string(rune('a'+i)) + "/layer.tar"
+		add(name, l)
+		names = append(names, name)
+	}
+	manifest, err := json.Marshal([]map[string]interface{}{{"Config": "config.json", "Layers": names}})
+	require.NoError(t, err)
+	add("manifest.json", manifest)
+	require.NoError(t, tw.Close())
+
+	p := filepath.Join(dir, "image.tar")
+	require.NoError(t, os.WriteFile(p, buf.Bytes(), 0o644))
+	return p
+}
+
+func writeOCILayout(t *testing.T, dir string, layers [][]byte) string {
+	blobs := filepath.Join(dir, "oci", "blobs", "sha256")
+	require.NoError(t, os.MkdirAll(blobs, 0o755))
+	writeBlob := func(b []byte) string {
+		d := digestOf(b)
+		require.NoError(t, os.WriteFile(filepath.Join(blobs, d[len("sha256:"):]), b, 0o644))
+		return d
+	}
+
+	var descs []map[string]interface{}
+	for _, l := range layers {
+		descs = append(descs, map[string]interface{}{
+			"mediaType": "application/vnd.oci.image.layer.v1.tar",
+			"digest":    writeBlob(l),
+		})
+	}
+	manifest, err := json.Marshal(map[string]interface{}{"schemaVersion": 2, "layers": descs})
+	require.NoError(t, err)
+	index, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"manifests":     []map[string]interface{}{{"digest": writeBlob(manifest)}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "oci", "index.json"), index, 0o644))
+	return filepath.Join(dir, "oci")
+}
+
+func TestScanImage(t *testing.T) {
+	tempDir := t.TempDir()
+	layers := testImageLayers(t)
+
+	comp := gora.NewCompiled()
+	err := comp.CompileString(`rule x { strings: $a = "test" condition: $a and file_path != "/etc/passwd" }`, "")
+	require.NoError(t, err)
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	var matches yara.MatchRules
+	comp.SetCallback(&matches)
+
+	expected := map[string]string{
+		"/opt/app/new.js": digestOf(layers[1]),
+		"/bin/tool":       digestOf(layers[1]),
+	}
+
+	spoolDir := t.TempDir()
+	for _, image := range []string{writeDockerSave(t, tempDir, layers), writeOCILayout(t, tempDir, layers)} {
+		scanned := map[string]bool{}
+		matched := map[string]string{}
+		err = comp.ScanImage(image, gora.ImageScanOptions{TempDir: spoolDir}, func(file gora.ImageFile, err error) error {
+			require.NoError(t, err)
+			require.False(t, scanned[file.Path], "scanned twice %s", file.Path)
+			scanned[file.Path] = true
+			if len(matches) > 0 {
+				matched[file.Path] = file.Layer
+			}
+			matches = nil
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]bool{
+			"/etc/passwd": true, "/opt/app/new.js": true, "/bin/tool": true, "/var/data": true,
+		}, scanned)
+		require.Equal(t, expected, matched)
+
+		// the temporary files of the decompressed layers are removed.
+		entries, err := os.ReadDir(spoolDir)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	}
+}
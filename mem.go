@@ -0,0 +1,186 @@
+package gora
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/hillu/go-yara/v4"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// ReaderOptions holds the settings of reading the data scanned by ScanReader and ScanProcMemory. The zero value reads
+// the readers into memory to be scanned at once.
+type ReaderOptions struct {
+	// ChunkSize is the size of the chunks ScanReader scans the readers larger than it in. Zero reads the readers
+	// into memory to be scanned at once.
+	ChunkSize int
+	// ChunkOverlap is the number of bytes each chunk shares with the previous chunk. It must be less than ChunkSize.
+	ChunkOverlap int
+}
+
+// SetReaderOptions sets the options of reading the data scanned by ScanReader and ScanProcMemory.
+func (c *Compiled) SetReaderOptions(opts ReaderOptions) *Compiled {
+	c.reader = opts
+	return c
+}
+
+// ReaderOptions returns the options set by SetReaderOptions.
+func (c *Compiled) ReaderOptions() ReaderOptions {
+	return c.reader
+}
+
+// ScanMem defines the variables using the given scan context, then scans the given buffer. The scan is aborted when
+// the context of the scan context is done.
+//
+// The buffer is not in the file system, therefore set a virtual file path and a variables.VirtualFileInfo to the scan
+// context to define the file variables such as file_name and file_extension. The errors are returned as *ScanError.
+func (c *Compiled) ScanMem(buf []byte, sctx variables.ScanContext) error {
+	if err := c.DefineScannerVariables(sctx); err != nil {
+		return err
+	}
+	src := &evidenceSource{path: sctx.FilePath(), r: bytes.NewReader(buf)}
+	err := c.scanContext(sctx.Context(), true, src, func() error {
+		return c.scanner.ScanMem(buf)
+	})
+	return memScanError(err, sctx.FilePath())
+}
+
+// ScanReader defines the variables using the given scan context, then scans the data read from the given reader. The
+// size is the number of bytes to be read from the reader, or negative to read until EOF. See ScanMem for the details.
+//
+// The data is read into memory to be scanned unless ReaderOptions.ChunkSize is set and the size is either unknown or
+// larger than the chunk size. In the chunked mode, the data is scanned in chunks using the memory block iterator of
+// libyara, and each chunk starts with the last ReaderOptions.ChunkOverlap bytes of the previous chunk to match the
+// strings crossing the chunk boundaries. Note that the matches in the overlaps may be reported twice, and the modules
+// only see the first chunk. The filesize keyword is undefined in the chunked mode if the size is unknown.
+func (c *Compiled) ScanReader(r io.Reader, size int64, sctx variables.ScanContext) error {
+	opts := c.reader
+	if opts.ChunkSize <= 0 || (size >= 0 && size <= int64(opts.ChunkSize)) {
+		if size >= 0 {
+			r = io.LimitReader(r, size)
+		}
+		buf, err := io.ReadAll(r)
+		if err != nil {
+			return memScanError(err, sctx.FilePath())
+		}
+		return c.ScanMem(buf, sctx)
+	}
+
+	if opts.ChunkOverlap < 0 || opts.ChunkOverlap >= opts.ChunkSize {
+		return fmt.Errorf("invalid chunk overlap %d for chunk size %d", opts.ChunkOverlap, opts.ChunkSize)
+	}
+	if err := c.DefineScannerVariables(sctx); err != nil {
+		return err
+	}
+
+	blocks := newReaderBlocks(r, size, opts.ChunkSize, opts.ChunkOverlap)
+	var it yara.MemoryBlockIterator = blocks
+	if size >= 0 {
+		it = readerBlocksWithSize{blocks}
+	}
+	err := c.scanContext(sctx.Context(), true, &evidenceSource{path: sctx.FilePath()}, func() error {
+		return c.scanner.ScanMemBlocks(it)
+	})
+	if err == nil {
+		err = blocks.err
+	}
+	return memScanError(err, sctx.FilePath())
+}
+
+// memScanError returns the *ScanError of the given memory scan error.
+func memScanError(err error, path string) error {
+	if err == nil {
+		return nil
+	}
+	return &ScanError{Kind: classify(err), Path: path, Err: err}
+}
+
+// readerBlocks is a yara.MemoryBlockIterator reading the chunks of a reader. It can be iterated once only, since the
+// reader cannot be rewound.
+type readerBlocks struct {
+	r       io.Reader
+	size    int64
+	overlap int
+	buf     []byte
+	n       int
+	base    uint64
+	eof     bool
+	started bool
+	err     error
+}
+
+// readerBlocksWithSize is the readerBlocks implementing yara.MemoryBlockIteratorWithFilesize, used if the size of the
+// reader is known.
+type readerBlocksWithSize struct {
+	*readerBlocks
+}
+
+var (
+	_ yara.MemoryBlockIterator             = (*readerBlocks)(nil)
+	_ yara.MemoryBlockIteratorWithFilesize = readerBlocksWithSize{}
+)
+
+func newReaderBlocks(r io.Reader, size int64, chunkSize, overlap int) *readerBlocks {
+	if size >= 0 {
+		r = io.LimitReader(r, size)
+	}
+	return &readerBlocks{
+		r:       r,
+		size:    size,
+		overlap: overlap,
+		buf:     make([]byte, chunkSize),
+	}
+}
+
+// First implements the yara.MemoryBlockIterator interface.
+func (rb *readerBlocks) First() *yara.MemoryBlock {
+	if rb.started {
+		rb.err = errors.New("reader cannot be scanned twice")
+		return nil
+	}
+	rb.started = true
+	return rb.read(0)
+}
+
+// Next implements the yara.MemoryBlockIterator interface.
+func (rb *readerBlocks) Next() *yara.MemoryBlock {
+	if rb.eof || rb.err != nil || rb.n <= rb.overlap {
+		return nil
+	}
+	keep := rb.overlap
+	copy(rb.buf, rb.buf[rb.n-keep:rb.n])
+	rb.base += uint64(rb.n - keep)
+	return rb.read(keep)
+}
+
+// Filesize implements the yara.MemoryBlockIteratorWithFilesize interface.
+func (rb readerBlocksWithSize) Filesize() uint64 {
+	return uint64(rb.size)
+}
+
+// read reads the next chunk after the first keep bytes of the buffer, and returns its memory block.
+func (rb *readerBlocks) read(keep int) *yara.MemoryBlock {
+	n, err := io.ReadFull(rb.r, rb.buf[keep:])
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		rb.eof = true
+	case err != nil:
+		rb.err = err
+		return nil
+	}
+	if n == 0 && keep > 0 {
+		return nil
+	}
+	rb.n = keep + n
+	data := rb.buf[:rb.n]
+	return &yara.MemoryBlock{
+		Base: rb.base,
+		Size: uint64(len(data)),
+		FetchData: func(buf []byte) {
+			copy(buf, data)
+		},
+	}
+}
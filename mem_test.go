@@ -0,0 +1,74 @@
+package gora_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hillu/go-yara/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+	"github.com/binalyze/gora/variables"
+)
+
+func TestScanMem(t *testing.T) {
+	comp := gora.NewCompiled()
+	err := comp.CompileString(`
+	rule js {
+		strings:
+			$a = "test"
+		condition:
+			file_extension == "js" and file_modified_time > 0 and $a
+	}`, "")
+	require.NoError(t, err)
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	data := []byte("a test buffer")
+
+	var sctx variables.ScanContextImpl
+	sctx.SetFilePath("/archive.zip!/docs/x.js")
+	sctx.SetFileInfo(&variables.VirtualFileInfo{
+		FileName:     "x.js",
+		FileSize:     int64(len(data)),
+		ModifiedTime: time.Now(),
+	})
+
+	var matches yara.MatchRules
+	comp.SetCallback(&matches)
+	require.NoError(t, comp.ScanMem(data, &sctx))
+	require.Len(t, matches, 1)
+
+	matches = nil
+	require.NoError(t, comp.ScanReader(bytes.NewReader(data), -1, &sctx))
+	require.Len(t, matches, 1)
+
+	sctx.SetFilePath("/x.txt")
+	matches = nil
+	require.NoError(t, comp.ScanMem(data, &sctx))
+	require.Empty(t, matches)
+}
+
+func TestScanReaderChunked(t *testing.T) {
+	comp := gora.NewCompiled()
+	err := comp.CompileString(`rule x { strings: $a = "boundary" condition: $a and filesize > 100 }`, "")
+	require.NoError(t, err)
+	comp.SetReaderOptions(gora.ReaderOptions{ChunkSize: 64, ChunkOverlap: 16})
+	require.Equal(t, gora.ReaderOptions{ChunkSize: 64, ChunkOverlap: 16}, comp.ReaderOptions())
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	// the string crosses the first chunk boundary.
+	data := strings.Repeat("x", 60) + "boundary" + strings.Repeat("y", 100)
+
+	var matches yara.MatchRules
+	comp.SetCallback(&matches)
+	var sctx variables.ScanContextImpl
+	require.NoError(t, comp.ScanReader(strings.NewReader(data), int64(len(data)), &sctx))
+	require.Len(t, matches, 1)
+
+	comp.SetReaderOptions(gora.ReaderOptions{ChunkSize: 64, ChunkOverlap: 64})
+	require.Error(t, comp.ScanReader(strings.NewReader(data), -1, &sctx))
+}
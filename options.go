@@ -0,0 +1,137 @@
+package gora
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hillu/go-yara/v4"
+)
+
+// ScannerOptions holds the settings applied to the scanners created by Compiled. The zero value is the libyara
+// defaults.
+type ScannerOptions struct {
+	// Timeout stops the scan after the given duration. It has a resolution of seconds. Zero means no timeout.
+	Timeout time.Duration
+	// FastScan avoids multiple matches of the same string when not necessary.
+	FastScan bool
+	// ProcessMemory makes the scanned data to be treated as process memory.
+	ProcessMemory bool
+	// MaxMatchesPerString stops the scan when a string of a matching rule has more matches. The matching rule is
+	// still reported to the callback. Zero means no limit other than the libyara one, which stops matching the
+	// string only.
+	MaxMatchesPerString int
+	// MaxMatchData is the maximum number of bytes of data kept for each match. Zero keeps the libyara default.
+	//
+	// Note that it is a process wide libyara setting, which applies to all scanners.
+	MaxMatchData int
+	// StackSize is the size of the libyara stack used while evaluating the conditions. Zero keeps the libyara
+	// default.
+	//
+	// Note that it is a process wide libyara setting, which applies to all scanners.
+	StackSize int
+	// SkipNonMatching stops reporting the rules not matching to the callback even if it implements
+	// yara.ScanCallbackNoMatch.
+	SkipNonMatching bool
+}
+
+// ScanLimit is the limit stopping a scan.
+type ScanLimit int
+
+const (
+	// LimitNone is reported when the scan is not stopped by a limit.
+	LimitNone ScanLimit = iota
+	// LimitTimeout is reported when the scan is stopped by the timeout.
+	LimitTimeout
+	// LimitMaxMatches is reported when the scan is stopped since a string of a matching rule has more matches than
+	// ScannerOptions.MaxMatchesPerString.
+	LimitMaxMatches
+	// LimitTooManyMatches is reported when a string reaches the libyara match limit, and its matching is stopped.
+	LimitTooManyMatches
+)
+
+var scanLimitNames = [...]string{
+	LimitNone:           "none",
+	LimitTimeout:        "timeout",
+	LimitMaxMatches:     "max matches",
+	LimitTooManyMatches: "too many matches",
+}
+
+// String implements the fmt.Stringer interface.
+func (l ScanLimit) String() string {
+	if l >= 0 && int(l) < len(scanLimitNames) {
+		return scanLimitNames[l]
+	}
+	return fmt.Sprintf("ScanLimit(%d)", int(l))
+}
+
+// SetScannerOptions sets the options applied to the scanners created by CreateScanner and NewScanner. If the scanner
+// is already created, the options are applied to it as well.
+func (c *Compiled) SetScannerOptions(opts ScannerOptions) *Compiled {
+	c.options = &opts
+	c.timeout = opts.Timeout
+	if c.scanner != nil {
+		// errors are reported on creating the scanners.
+		_ = c.applyOptions(c.scanner)
+	}
+	return c
+}
+
+// ScannerOptions returns the options set by SetScannerOptions.
+func (c *Compiled) ScannerOptions() ScannerOptions {
+	if c.options == nil {
+		return ScannerOptions{}
+	}
+	return *c.options
+}
+
+// NewScanner creates a new scanner of the compiled rules with the scanner options applied. It is to create the
+// scanners of a scanner pool sharing the same rules. Use CreateScanner to create the scanner of Compiled.
+func (c *Compiled) NewScanner() (*yara.Scanner, error) {
+	if c.rules == nil {
+		return nil, errors.New("rules are not compiled")
+	}
+	s, err := yara.NewScanner(c.rules)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.applyOptions(s); err != nil {
+		s.Destroy()
+		return nil, err
+	}
+	return s, nil
+}
+
+// LimitReached returns the limit stopping the last scan, or LimitNone if the scan is not stopped by a limit.
+func (c *Compiled) LimitReached() ScanLimit {
+	return c.limit
+}
+
+func (c *Compiled) applyOptions(s *yara.Scanner) error {
+	if c.options == nil {
+		return nil
+	}
+	opts := c.options
+
+	var flags yara.ScanFlags
+	if opts.FastScan {
+		flags |= yara.ScanFlagsFastMode
+	}
+	if opts.ProcessMemory {
+		flags |= yara.ScanFlagsProcessMemory
+	}
+	s.SetFlags(flags)
+	s.SetTimeout(opts.Timeout)
+
+	if opts.MaxMatchData > 0 {
+		if err := yara.SetConfiguration(yara.ConfigMaxMatchData, opts.MaxMatchData); err != nil {
+			return fmt.Errorf("max match data: %w", err)
+		}
+	}
+	if opts.StackSize > 0 {
+		if err := yara.SetConfiguration(yara.ConfigStackSize, opts.StackSize); err != nil {
+			return fmt.Errorf("stack size: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package gora_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hillu/go-yara/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+)
+
+func TestScannerOptions(t *testing.T) {
+	tempDir := t.TempDir()
+	path := genFile(t, tempDir, strings.Repeat("test ", 10))
+
+	comp := gora.NewCompiled()
+	err := comp.CompileString(`rule x { strings: $a = "test" condition: $a } rule y { condition: false }`, "")
+	require.NoError(t, err)
+
+	opts := gora.ScannerOptions{
+		Timeout:             10 * time.Second,
+		FastScan:            true,
+		MaxMatchesPerString: 5,
+	}
+	comp.SetScannerOptions(opts)
+	require.Equal(t, opts, comp.ScannerOptions())
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	var matches yara.MatchRules
+	comp.SetCallback(&matches)
+	require.NoError(t, comp.ScanFile(path))
+	require.Len(t, matches, 1)
+	require.Equal(t, gora.LimitNone, comp.LimitReached())
+
+	opts.FastScan = false
+	comp.SetScannerOptions(opts)
+	matches = nil
+	require.NoError(t, comp.ScanFile(path))
+	require.Len(t, matches, 1)
+	require.Equal(t, gora.LimitMaxMatches, comp.LimitReached())
+	require.Equal(t, "max matches", comp.LimitReached().String())
+
+	s, err := comp.NewScanner()
+	require.NoError(t, err)
+	s.Destroy()
+}
+
+// noMatchRules collects the names of the rules not matching.
+type noMatchRules struct {
+	yara.MatchRules
+	notMatching []string
+}
+
+func (r *noMatchRules) RuleNotMatching(_ *yara.ScanContext, rule *yara.Rule) (bool, error) {
+	r.notMatching = append(r.notMatching, rule.Identifier())
+	return false, nil
+}
+
+func TestScannerOptionsSkipNonMatching(t *testing.T) {
+	path := genFile(t, t.TempDir(), "test")
+
+	comp := gora.NewCompiled()
+	require.NoError(t, comp.CompileString(`rule x { strings: $a = "test" condition: $a } rule y { condition: false }`, ""))
+	// the rules not matching are reported with the other options set.
+	comp.SetScannerOptions(gora.ScannerOptions{FastScan: true})
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	var rules noMatchRules
+	comp.SetCallback(&rules)
+	require.NoError(t, comp.ScanFile(path))
+	require.Len(t, rules.MatchRules, 1)
+	require.Equal(t, []string{"y"}, rules.notMatching)
+
+	comp.SetScannerOptions(gora.ScannerOptions{FastScan: true, SkipNonMatching: true})
+	rules = noMatchRules{}
+	require.NoError(t, comp.ScanFile(path))
+	require.Len(t, rules.MatchRules, 1)
+	require.Empty(t, rules.notMatching)
+}
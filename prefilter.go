@@ -0,0 +1,77 @@
+package gora
+
+import (
+	"github.com/VirusTotal/gyp/ast"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// PrefilterStats holds the statistics of the prefilter.
+type PrefilterStats struct {
+	// Evaluated is the number of the file scan contexts evaluated by the prefilter.
+	Evaluated uint64
+	// Skipped is the number of the file scans skipped since no rule could match.
+	Skipped uint64
+}
+
+// SetPrefilter enables or disables the prefilter. It must be called before compiling the rules.
+//
+// If it is enabled, the parts of the rule conditions which only depend on the external variables and the file size
+// are evaluated by DefineScannerVariables for the file system scan contexts, before the file is opened. The variable
+// values calculated by the prefilter are reused to define the variables, within the valuer timeout. If no rule can
+// match, the variables are not defined and the subsequent ScanFile or ScanFileDescriptor call returns without
+// scanning, and without calling the callback. The skip applies to the next scan only, and it is cleared by the other
+// scans. Skipped scans are counted in PrefilterStats.
+//
+// The prefilter is disabled if any of the rules cannot be parsed by gyp or uses includes.
+func (c *Compiled) SetPrefilter(enable bool) *Compiled {
+	c.prefilterEnabled = enable
+	return c
+}
+
+// PrefilterStats returns the statistics of the prefilter.
+func (c *Compiled) PrefilterStats() PrefilterStats {
+	return PrefilterStats{
+		Evaluated: c.prefilterEvaluated.Load(),
+		Skipped:   c.prefilterSkipped.Load(),
+	}
+}
+
+// Skipped reports whether the next file scan is skipped by the prefilter.
+func (c *Compiled) Skipped() bool {
+	return c.skip
+}
+
+func (c *Compiled) buildPrefilter(ruleNs []RuleNamespace, ruleSets []*ast.RuleSet, unparsed map[string]bool) {
+	c.prefilter = nil
+	if !c.prefilterEnabled || len(unparsed) > 0 {
+		return
+	}
+
+	prefilter := variables.NewPrefilter()
+	for i, rule := range ruleNs {
+		prefilter.Add(ruleSets[i], namespaceKey(rule.Namespace))
+	}
+	c.prefilter = prefilter
+}
+
+// prefilterSkip evaluates the prefilter for the given values of a scan target and reports whether the scan should be
+// skipped.
+func (c *Compiled) prefilterSkip(values *variables.Values) bool {
+	if c.prefilter == nil || !values.ScanContext().InFileSystem() {
+		return false
+	}
+	c.prefilterEvaluated.Add(1)
+	if c.prefilter.CanMatchValues(values) {
+		return false
+	}
+	c.prefilterSkipped.Add(1)
+	return true
+}
+
+// consumeSkip reports whether the current scan is skipped and resets the skip flag.
+func (c *Compiled) consumeSkip() bool {
+	skip := c.skip
+	c.skip = false
+	return skip
+}
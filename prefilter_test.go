@@ -0,0 +1,56 @@
+package gora_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hillu/go-yara/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+	"github.com/binalyze/gora/variables"
+)
+
+func TestPrefilterSkipsScan(t *testing.T) {
+	tempDir := t.TempDir()
+
+	comp := gora.NewCompiled().SetPrefilter(true)
+	err := comp.CompileString(`
+	rule txt {
+		strings:
+			$a = "test"
+		condition:
+			file_extension == "txt" and $a
+	}`, "")
+	require.NoError(t, err)
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	scan := func(path string) yara.MatchRules {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+
+		var sctx variables.ScanContextImpl
+		sctx.SetInFileSystem(true)
+		sctx.SetFilePath(path)
+		sctx.SetFileInfo(info)
+
+		var matches yara.MatchRules
+		comp.SetCallback(&matches)
+		require.NoError(t, comp.DefineScannerVariables(&sctx))
+		require.NoError(t, comp.ScanFile(path))
+		return matches
+	}
+
+	require.Len(t, scan(genFileExt(t, tempDir, "test", ".txt")), 1)
+	require.False(t, comp.Skipped())
+	require.Empty(t, scan(genFileExt(t, tempDir, "test", ".bin")))
+	require.Equal(t, gora.PrefilterStats{Evaluated: 2, Skipped: 1}, comp.PrefilterStats())
+}
+
+func genFileExt(t *testing.T, dir, content, ext string) string {
+	t.Helper()
+	p := genFile(t, dir, content)
+	require.NoError(t, os.Rename(p, p+ext))
+	return p + ext
+}
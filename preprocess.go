@@ -0,0 +1,76 @@
+package gora
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/VirusTotal/gyp/ast"
+	"github.com/VirusTotal/gyp/parser"
+)
+
+// defaultNamespace is the namespace used by yara for the rules added without a namespace.
+const defaultNamespace = "default"
+
+// preprocessRules parses the given rules if pruning or prefiltering is enabled, prunes them and builds the
+// prefilter. It returns the pruned sources by their indexes in the given slice. Rules missing in the returned map must
+// be compiled as is.
+func (c *Compiled) preprocessRules(ruleNs []RuleNamespace) (map[int]string, error) {
+	if !c.prune && !c.prefilterEnabled {
+		c.pruned = nil
+		c.prefilter = nil
+		return nil, nil
+	}
+
+	ruleSets, unparsed := parseRules(ruleNs)
+	pruned, err := c.pruneRules(ruleNs, ruleSets, unparsed)
+	if err != nil {
+		return nil, err
+	}
+	c.buildPrefilter(ruleNs, ruleSets, unparsed)
+	return pruned, nil
+}
+
+// preprocessFiles reads the given rule files and preprocesses them. See preprocessRules.
+func (c *Compiled) preprocessFiles(files []*os.File, filenameNS bool) (map[int]string, error) {
+	if !c.prune && !c.prefilterEnabled {
+		return c.preprocessRules(nil)
+	}
+
+	ruleNs := make([]RuleNamespace, 0, len(files))
+	for _, file := range files {
+		b, err := io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		// Rewind to be able to compile the original file if it is not pruned.
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		ruleNs = append(ruleNs, RuleNamespace{Rule: string(b), Namespace: fileNamespace(file, filenameNS)})
+	}
+	return c.preprocessRules(ruleNs)
+}
+
+// parseRules parses the given rules. It returns the parsed rule sets by their indexes in the given slice, and the
+// namespaces which have rules that cannot be parsed or use includes. Rule sets of these rules are nil.
+func parseRules(ruleNs []RuleNamespace) ([]*ast.RuleSet, map[string]bool) {
+	ruleSets := make([]*ast.RuleSet, len(ruleNs))
+	unparsed := make(map[string]bool)
+	for i, rule := range ruleNs {
+		rs, err := parser.Parse(strings.NewReader(rule.Rule))
+		if err != nil || len(rs.Includes) > 0 {
+			unparsed[namespaceKey(rule.Namespace)] = true
+			continue
+		}
+		ruleSets[i] = rs
+	}
+	return ruleSets, unparsed
+}
+
+func namespaceKey(namespace string) string {
+	if namespace == "" {
+		return defaultNamespace
+	}
+	return namespace
+}
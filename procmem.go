@@ -0,0 +1,63 @@
+package gora
+
+import (
+	"errors"
+	"path"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// ErrProcMemoryUnsupported is returned from ScanProcMemory and ProcMemoryRegions on the platforms other than Linux.
+var ErrProcMemoryUnsupported = errors.New("process memory regions are not supported on this platform")
+
+// defaultRegionChunkSize is the size of the chunks the memory regions are read in if ReaderOptions.ChunkSize is not set.
+const defaultRegionChunkSize = 1 << 20
+
+// RegionFilter selects the memory regions of a process to be scanned by ScanProcMemory. A region is selected if it
+// matches all the criteria set. The regions which are not readable are never scanned.
+type RegionFilter struct {
+	// Perms are the permissions the regions must have, such as "x" for the executable regions or "rwx".
+	Perms string
+	// Anonymous selects only the regions which are not backed by a file.
+	Anonymous bool
+	// FileBacked selects only the regions backed by a file.
+	FileBacked bool
+	// Paths are the path.Match patterns of the region paths, such as "/usr/lib/*" or "[heap]". A region matching
+	// any of them is selected.
+	Paths []string
+	// MinSize and MaxSize are the limits of the region sizes if they are not zero.
+	MinSize uint64
+	MaxSize uint64
+	// Match is called for the regions matching the other criteria if it is not nil, and it returns whether the
+	// region is selected.
+	Match func(*variables.MemoryRegion) bool
+}
+
+// Selects reports whether the given region is selected by the filter. A nil filter selects all the readable regions.
+func (f *RegionFilter) Selects(r *variables.MemoryRegion) bool {
+	if !r.HasPerms("r") {
+		return false
+	}
+	if f == nil {
+		return true
+	}
+	switch {
+	case !r.HasPerms(f.Perms),
+		f.Anonymous && !r.Anonymous(),
+		f.FileBacked && r.Anonymous(),
+		f.MinSize > 0 && r.Size < f.MinSize,
+		f.MaxSize > 0 && r.Size > f.MaxSize,
+		len(f.Paths) > 0 && !matchRegionPath(f.Paths, r.Path):
+		return false
+	}
+	return f.Match == nil || f.Match(r)
+}
+
+func matchRegionPath(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+//go:build linux
+// +build linux
+
+package gora
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"syscall"
+
+	"github.com/hillu/go-yara/v4"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// ProcMemoryRegions returns the memory regions of the given process listed in /proc/<pid>/maps.
+func ProcMemoryRegions(pid int) ([]variables.MemoryRegion, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/maps", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint errcheck
+	return variables.ParseMemoryMaps(f)
+}
+
+// ScanProcMemory scans the memory regions of the given process selected by the given filter, instead of handing the
+// whole address space to libyara like ScanProc. The regions are listed from /proc/<pid>/maps and read from
+// /proc/<pid>/mem, which requires the ptrace access to the process, but the process is not stopped during the scan.
+//
+// Each region is scanned separately using the memory block iterator of libyara, in chunks of ReaderOptions.ChunkSize
+// or 1MiB, overlapping by ReaderOptions.ChunkOverlap bytes. The variables of the process are defined once, and the
+// region variables such as region_path, region_perms and region_anonymous are defined for each region using a scan
+// context wrapping the given scan context, which implements variables.MemoryRegionProvider. The base addresses of the
+// matches are the virtual addresses in the process. The regions which cannot be read, such as [vvar], are skipped.
+//
+// The scan is aborted when the context of the scan context is done, or when a region scan fails. The errors are
+// returned as *ScanError.
+func (c *Compiled) ScanProcMemory(pid int, sctx variables.ScanContext, filter *RegionFilter) error {
+	c.skip = false
+	opts := c.reader
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultRegionChunkSize
+	}
+	if opts.ChunkOverlap < 0 || opts.ChunkOverlap >= chunkSize {
+		return fmt.Errorf("invalid chunk overlap %d for chunk size %d", opts.ChunkOverlap, chunkSize)
+	}
+
+	regions, err := ProcMemoryRegions(pid)
+	if err != nil {
+		return procScanError(err, pid)
+	}
+	mem, err := os.Open(fmt.Sprintf("/proc/%d/mem", pid))
+	if err != nil {
+		return procScanError(err, pid)
+	}
+	defer mem.Close() // nolint errcheck
+
+	procVars, regionVars := splitRegionVariables(c.vars)
+	err = procVars.DefineScannerVariables(&regionScanContext{ScanContext: sctx}, c.scanner)
+	if err = cancelledError(err); err != nil {
+		return err
+	}
+
+	blocks := &regionBlocks{mem: mem, buf: make([]byte, chunkSize), overlap: opts.ChunkOverlap}
+	for i := range regions {
+		region := &regions[i]
+		// The kernel addresses such as [vsyscall] cannot be read from /proc/<pid>/mem.
+		if region.Base > math.MaxInt64 || !filter.Selects(region) {
+			continue
+		}
+		if !blocks.reset(region) {
+			if blocks.err != nil {
+				return procScanError(blocks.err, pid)
+			}
+			continue
+		}
+
+		rctx := &regionScanContext{ScanContext: sctx, region: region}
+		if err := cancelledError(regionVars.DefineScannerVariables(rctx, c.scanner)); err != nil {
+			return err
+		}
+		src := &evidenceSource{pid: pid, region: region, r: mem}
+		err := c.scanContext(sctx.Context(), false, src, func() error {
+			return c.scanner.ScanMemBlocks(blocks)
+		})
+		if err == nil {
+			err = blocks.err
+		}
+		if err != nil {
+			return procScanError(err, pid)
+		}
+	}
+	return nil
+}
+
+// splitRegionVariables splits the given variables into the variables of the process, which are defined once for the
+// process by ScanProcMemory, and the region variables such as region_path, which are defined for each region.
+func splitRegionVariables(vars *variables.Variables) (*variables.Variables, *variables.Variables) {
+	regionVars, _ := variables.Select("region_*")
+	isRegion := make(map[variables.VariableType]bool, len(regionVars))
+	for _, v := range regionVars {
+		isRegion[v] = true
+	}
+
+	var procList, regionList []variables.VariableType
+	for _, v := range vars.Variables() {
+		if isRegion[v] {
+			regionList = append(regionList, v)
+		} else {
+			procList = append(procList, v)
+		}
+	}
+	proc, region := new(variables.Variables), new(variables.Variables)
+	proc.InitVariables(procList)
+	region.InitVariables(regionList)
+	proc.SetValuerTimeout(vars.ValuerTimeout())
+	region.SetValuerTimeout(vars.ValuerTimeout())
+	return proc, region
+}
+
+// regionScanContext is the scan context of a memory region. It provides the region to the scan context of the
+// process.
+type regionScanContext struct {
+	variables.ScanContext
+	region *variables.MemoryRegion
+}
+
+var (
+	_ variables.DefaultRecorder      = (*regionScanContext)(nil)
+	_ variables.MemoryRegionProvider = (*regionScanContext)(nil)
+	_ variables.ProcfsProvider       = (*regionScanContext)(nil)
+	_ variables.PathMapper           = (*regionScanContext)(nil)
+	_ variables.RealPathProvider     = (*regionScanContext)(nil)
+	_ variables.MountTableProvider   = (*regionScanContext)(nil)
+	_ variables.PackageDBProvider    = (*regionScanContext)(nil)
+)
+
+func (sc *regionScanContext) MemoryRegion() *variables.MemoryRegion {
+	return sc.region
+}
+
+// PathMapping forwards to the scan context of the process if it implements variables.PathMapper.
+func (sc *regionScanContext) PathMapping() *variables.PathMapping {
+	if m, ok := sc.ScanContext.(variables.PathMapper); ok {
+		return m.PathMapping()
+	}
+	return nil
+}
+
+// RealPath forwards to the scan context of the process if it implements variables.RealPathProvider.
+func (sc *regionScanContext) RealPath() string {
+	if p, ok := sc.ScanContext.(variables.RealPathProvider); ok {
+		return p.RealPath()
+	}
+	return sc.FilePath()
+}
+
+// MountTable forwards to the scan context of the process if it implements variables.MountTableProvider.
+func (sc *regionScanContext) MountTable() *variables.MountTable {
+	if p, ok := sc.ScanContext.(variables.MountTableProvider); ok {
+		return p.MountTable()
+	}
+	return nil
+}
+
+// PackageDB forwards to the scan context of the process if it implements variables.PackageDBProvider.
+func (sc *regionScanContext) PackageDB() *variables.PackageDB {
+	if p, ok := sc.ScanContext.(variables.PackageDBProvider); ok {
+		return p.PackageDB()
+	}
+	return nil
+}
+
+// Procfs forwards to the scan context of the process if it implements variables.ProcfsProvider.
+func (sc *regionScanContext) Procfs() *variables.Procfs {
+	if p, ok := sc.ScanContext.(variables.ProcfsProvider); ok {
+		return p.Procfs()
+	}
+	return nil
+}
+
+// RecordDefault forwards to the scan context of the process if it implements variables.DefaultRecorder.
+func (sc *regionScanContext) RecordDefault(v variables.VariableType, err error) {
+	if r, ok := sc.ScanContext.(variables.DefaultRecorder); ok {
+		r.RecordDefault(v, err)
+	}
+}
+
+// regionBlocks is a yara.MemoryBlockIterator reading the chunks of a memory region from /proc/<pid>/mem.
+type regionBlocks struct {
+	mem     *os.File
+	region  *variables.MemoryRegion
+	buf     []byte
+	overlap int
+	off     uint64
+	n       int
+	err     error
+}
+
+var _ yara.MemoryBlockIterator = (*regionBlocks)(nil)
+
+// reset sets the region to be iterated and reads its first chunk. It returns false if the region cannot be read, and
+// sets the error if it is not an expected read failure.
+func (rb *regionBlocks) reset(region *variables.MemoryRegion) bool {
+	rb.region, rb.off, rb.n, rb.err = region, 0, 0, nil
+	return rb.read(0)
+}
+
+// First implements the yara.MemoryBlockIterator interface.
+func (rb *regionBlocks) First() *yara.MemoryBlock {
+	if rb.off != 0 && !rb.read(0) {
+		return nil
+	}
+	return rb.block()
+}
+
+// Next implements the yara.MemoryBlockIterator interface.
+func (rb *regionBlocks) Next() *yara.MemoryBlock {
+	end := rb.off + uint64(rb.n)
+	if end >= rb.region.Size || rb.n <= rb.overlap || !rb.read(end-uint64(rb.overlap)) {
+		return nil
+	}
+	return rb.block()
+}
+
+// read reads the chunk at the given offset of the region. The read failures of the unmapped or inaccessible pages end
+// the region silently.
+func (rb *regionBlocks) read(off uint64) bool {
+	size := uint64(len(rb.buf))
+	if rem := rb.region.Size - off; rem < size {
+		size = rem
+	}
+	n, err := rb.mem.ReadAt(rb.buf[:size], int64(rb.region.Base+off))
+	if n == 0 {
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, syscall.EIO) && !errors.Is(err, syscall.EFAULT) {
+			rb.err = err
+		}
+		return false
+	}
+	rb.off, rb.n = off, n
+	return true
+}
+
+// block returns the memory block of the current chunk.
+func (rb *regionBlocks) block() *yara.MemoryBlock {
+	data := rb.buf[:rb.n]
+	return &yara.MemoryBlock{
+		Base: rb.region.Base + rb.off,
+		Size: uint64(len(data)),
+		FetchData: func(buf []byte) {
+			copy(buf, data)
+		},
+	}
+}
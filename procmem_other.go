@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package gora
+
+import "github.com/binalyze/gora/variables"
+
+// ProcMemoryRegions is not supported on this platform.
+func ProcMemoryRegions(int) ([]variables.MemoryRegion, error) {
+	return nil, ErrProcMemoryUnsupported
+}
+
+// ScanProcMemory is not supported on this platform. See ScanProc to scan the processes.
+func (c *Compiled) ScanProcMemory(int, variables.ScanContext, *RegionFilter) error {
+	return ErrProcMemoryUnsupported
+}
@@ -0,0 +1,87 @@
+package gora_test
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hillu/go-yara/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+	"github.com/binalyze/gora/variables"
+)
+
+func TestRegionFilter(t *testing.T) {
+	heap := &variables.MemoryRegion{Base: 0x1000, Size: 0x2000, Perms: "rwxp", Path: "[heap]"}
+	lib := &variables.MemoryRegion{Base: 0x8000, Size: 0x1000, Perms: "r-xp", Inode: 42, Path: "/usr/lib/libc.so.6"}
+	guard := &variables.MemoryRegion{Base: 0x9000, Size: 0x1000, Perms: "---p"}
+
+	var nilFilter *gora.RegionFilter
+	require.True(t, nilFilter.Selects(heap))
+	require.False(t, nilFilter.Selects(guard))
+
+	tests := []struct {
+		name   string
+		filter gora.RegionFilter
+		heap   bool
+		lib    bool
+	}{
+		{"executable", gora.RegionFilter{Perms: "x"}, true, true},
+		{"anonymous rwx", gora.RegionFilter{Perms: "rwx", Anonymous: true}, true, false},
+		{"file backed", gora.RegionFilter{FileBacked: true}, false, true},
+		{"paths", gora.RegionFilter{Paths: []string{"/usr/lib/*"}}, false, true},
+		{"min size", gora.RegionFilter{MinSize: 0x2000}, true, false},
+		{"max size", gora.RegionFilter{MaxSize: 0x1000}, false, true},
+		{"match", gora.RegionFilter{Match: func(r *variables.MemoryRegion) bool { return r.Base == 0x1000 }}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.heap, tt.filter.Selects(heap))
+			require.Equal(t, tt.lib, tt.filter.Selects(lib))
+		})
+	}
+}
+
+func TestScanProcMemory(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process memory regions are supported on linux only")
+	}
+
+	regions, err := gora.ProcMemoryRegions(os.Getpid())
+	require.NoError(t, err)
+	require.NotEmpty(t, regions)
+
+	comp := gora.NewCompiled()
+	err = comp.CompileString(`
+	rule marker {
+		strings:
+			$a = "gora-region-marker"
+		condition:
+			region_anonymous and region_perms startswith "rw" and region_base > 0 and
+			process_id == `+strconv.Itoa(os.Getpid())+` and $a
+	}`, "")
+	require.NoError(t, err)
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	marker := []byte(strings.Repeat("gora-region-marker", 64))
+
+	var sctx variables.ScanContextImpl
+	sctx.SetInProcess(true)
+	sctx.SetPid(os.Getpid())
+
+	var matches yara.MatchRules
+	comp.SetCallback(&matches)
+	err = comp.ScanProcMemory(os.Getpid(), &sctx, &gora.RegionFilter{Perms: "rw", Anonymous: true})
+	require.NoError(t, err)
+	require.NotEmpty(t, matches)
+	runtime.KeepAlive(marker)
+
+	matches = nil
+	err = comp.ScanProcMemory(os.Getpid(), &sctx, &gora.RegionFilter{FileBacked: true, Perms: "x"})
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
@@ -0,0 +1,95 @@
+package gora
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/VirusTotal/gyp/ast"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// PrunedRule represents a rule dropped at compile time since it can never match on this host.
+type PrunedRule struct {
+	Rule      string
+	Namespace string
+}
+
+// SetPruning enables or disables compile time pruning. If it is enabled, the host constant variables such as os and
+// os_linux are substituted into the rule conditions, the conditions are folded, and the rules which become statically
+// false are not compiled. Dropped rules can be listed with PrunedRules after compilation.
+//
+// Rules which cannot be parsed by gyp, or use includes, are compiled as is, together with all other rules in the
+// same namespace.
+func (c *Compiled) SetPruning(enable bool) *Compiled {
+	c.prune = enable
+	return c
+}
+
+// PrunedRules returns the rules dropped at compile time.
+func (c *Compiled) PrunedRules() []PrunedRule {
+	pruned := make([]PrunedRule, len(c.pruned))
+	copy(pruned, c.pruned)
+	return pruned
+}
+
+// pruneRules prunes the given parsed rule sets and returns the pruned sources by their indexes in the given slice.
+// Rules missing in the returned map must be compiled as is.
+func (c *Compiled) pruneRules(ruleNs []RuleNamespace, ruleSets []*ast.RuleSet, unparsed map[string]bool) (
+	map[int]string, error) {
+	c.pruned = nil
+	sources := make(map[int]string, len(ruleNs))
+	if !c.prune {
+		return sources, nil
+	}
+
+	// Only the defined variables are substituted not to compile the rules referring to the undefined ones.
+	consts := variables.HostConstants()
+	defined := make(map[variables.VariableType]struct{})
+	for _, v := range c.vars.Variables() {
+		defined[v] = struct{}{}
+	}
+	for v := range consts {
+		if _, ok := defined[v]; !ok {
+			delete(consts, v)
+		}
+	}
+
+	// The rules referenced by the rule sets of any source in a namespace are protected before pruning, since a later
+	// source may refer to the rules of an earlier one, such as the files compiled into the default namespace.
+	pruners := make(map[string]*variables.Pruner)
+	for i, rule := range ruleNs {
+		ns := namespaceKey(rule.Namespace)
+		if unparsed[ns] {
+			// Rules in the same namespace may refer to the rules which cannot be parsed, therefore none of them can
+			// be dropped.
+			continue
+		}
+		pruner, ok := pruners[ns]
+		if !ok {
+			pruner = variables.NewPruner(consts)
+			pruners[ns] = pruner
+		}
+		pruner.Protect(ruleSets[i])
+	}
+
+	for i, rule := range ruleNs {
+		ns := namespaceKey(rule.Namespace)
+		pruner, ok := pruners[ns]
+		if !ok {
+			continue
+		}
+
+		dropped := pruner.Prune(ruleSets[i])
+
+		var sb strings.Builder
+		if err := ruleSets[i].WriteSource(&sb); err != nil {
+			return nil, fmt.Errorf("prune rules error: %w", err)
+		}
+		sources[i] = sb.String()
+		for _, name := range dropped {
+			c.pruned = append(c.pruned, PrunedRule{Rule: name, Namespace: ns})
+		}
+	}
+	return sources, nil
+}
@@ -0,0 +1,62 @@
+package gora_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+)
+
+func TestCompilePruning(t *testing.T) {
+	comp := gora.NewCompiled().SetPruning(true)
+	err := comp.CompileStrings([]gora.RuleNamespace{
+		{Rule: rulestrPrune, Namespace: "ns1"},
+		{Rule: `rule other { condition: not_this_os or true }`, Namespace: "ns1"},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, comp.Rules())
+	require.Equal(t, []gora.PrunedRule{{Rule: "not_this_os", Namespace: "ns1"}}, comp.PrunedRules())
+
+	for _, r := range comp.Rules().GetRules() {
+		require.NotEqual(t, "not_this_os", r.Identifier())
+	}
+}
+
+func TestCompilePruningFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	comp := gora.NewCompiled().SetPruning(true)
+	path := genFile(t, tempDir, rulestrPrune)
+	err := comp.CompileFiles(false, path)
+	require.NoError(t, err)
+	require.Equal(t, []gora.PrunedRule{{Rule: "not_this_os", Namespace: "default"}}, comp.PrunedRules())
+
+	comp = gora.NewCompiled()
+	err = comp.CompileFiles(false, path)
+	require.NoError(t, err)
+	require.Empty(t, comp.PrunedRules())
+	require.Len(t, comp.Rules().GetRules(), 2)
+}
+
+func TestCompilePruningProtectsAcrossFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// The rule set of the second file refers to the rule of the first file in the default namespace.
+	first := genFile(t, tempDir, `rule set_other_os { condition: os != "`+runtime.GOOS+`" }`)
+	second := genFile(t, tempDir, `
+rule set_this_os { condition: os == "`+runtime.GOOS+`" }
+rule set_user { condition: all of (set_*) }
+`)
+	comp := gora.NewCompiled().SetPruning(true)
+	err := comp.CompileFiles(false, first, second)
+	require.NoError(t, err)
+	require.Empty(t, comp.PrunedRules())
+	require.Len(t, comp.Rules().GetRules(), 3)
+}
+
+var rulestrPrune = `
+rule not_this_os { condition: os != "` + runtime.GOOS + `" }
+rule this_os { condition: os == "` + runtime.GOOS + `" and filesize > 0 }
+`
@@ -0,0 +1,56 @@
+package gora
+
+import (
+	"errors"
+
+	"github.com/hillu/go-yara/v4"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// ErrProcessSweepUnsupported is returned from ScanProcesses on the platforms other than Linux.
+var ErrProcessSweepUnsupported = errors.New("process sweep is not supported on this platform")
+
+// ProcessSweepOptions holds the settings of ScanProcesses.
+type ProcessSweepOptions struct {
+	ScanOptions
+	// Procfs is set to the scan contexts as the procfs settings of the process variables. Its cached socket tables
+	// are reset at the start of the sweep.
+	Procfs *variables.Procfs
+	// Packages is set to the scan contexts of the files of the processes in the mount namespace of the current
+	// process as the package database of the package variables, such as file_package_owned. The files of the
+	// processes in the other mount namespaces, such as the containers, are looked up in the package databases read
+	// from their /proc/<pid>/root directories with the same settings. The package database of the host is used if it
+	// is nil.
+	Packages *variables.PackageDB
+}
+
+// ProcessResult is the combined result of scanning a process and its backing binaries.
+type ProcessResult struct {
+	Pid int
+	// Path is the path of the executable of the process.
+	Path string
+	// Matches are the rules matching the memory of the process.
+	Matches yara.MatchRules
+	// Err is the error of scanning the memory of the process.
+	Err error
+	// Exe is the result of the executable of the process. It is nil if the executable is not found.
+	Exe *FileResult
+	// Libraries are the results of the shared objects mapped by the process.
+	Libraries []*FileResult
+}
+
+// FileResult is the result of a backing binary scanned by ScanProcesses. The results of the binaries mapped by many
+// processes are shared by their ProcessResults.
+type FileResult struct {
+	// Path is the path of the file in the mount namespace of the process, which is set to file_path.
+	Path string
+	// Matches are the rules matching the file.
+	Matches yara.MatchRules
+	// Err is the error of scanning the file.
+	Err error
+}
+
+// ProcessScanFunc is called by ScanProcesses with the result of each process. Returning an error stops
+// ScanProcesses, and the error is returned.
+type ProcessScanFunc func(result *ProcessResult) error
@@ -0,0 +1,227 @@
+//go:build linux
+// +build linux
+
+package gora
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+
+	"github.com/binalyze/gora/variables"
+)
+
+// ScanProcesses scans the given processes together with their backing binaries, or all the processes except the
+// current one if pids is nil. For each process, its memory is scanned like ScanProc with the process variables, then
+// its executable and the shared objects mapped executable in /proc/<pid>/maps are scanned like ScanFile with the file
+// variables. The files are read through /proc/<pid>/root, so the files of the processes in the containers are scanned
+// as well. The results are combined into one ProcessResult per process, and passed to the given function.
+//
+// Each file is scanned only once per call, even if it is mapped by many processes, and its result is shared by the
+// processes. The callback of the scanner, set either by SetCallback or directly on Scanner, is replaced during the scans
+// to collect the matches, and restored when ScanProcesses returns.
+func (c *Compiled) ScanProcesses(pids []int, opts ProcessSweepOptions, fn ProcessScanFunc) error {
+	opts.Procfs = sweepProcfs(opts.Procfs)
+	s := &processSweep{
+		c:        c,
+		ctx:      opts.ctx(),
+		opts:     opts,
+		files:    make(map[fileID]*FileResult),
+		packages: make(map[string]*variables.PackageDB),
+	}
+	s.mntNS, _ = os.Readlink("/proc/self/ns/mnt")
+	if pids == nil {
+		var err error
+		if pids, err = listProcesses(); err != nil {
+			return err
+		}
+	}
+
+	cb, scannerCb := c.callback, c.scanner.Callback
+	defer func() {
+		c.callback = cb
+		c.scanner.SetCallback(scannerCb)
+	}()
+	for _, pid := range pids {
+		if err := s.ctx.Err(); err != nil {
+			return &CancelledError{Err: err}
+		}
+		res := s.scanProcess(pid)
+		if errors.Is(res.Err, ErrCancelled) {
+			return res.Err
+		}
+		if fn != nil {
+			if err := fn(res); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sweepProcfs returns the given procfs settings with their cached socket tables reset, or the default settings if they
+// are nil, so the socket tables are read once per sweep.
+func sweepProcfs(p *variables.Procfs) *variables.Procfs {
+	if p == nil {
+		return &variables.Procfs{}
+	}
+	p.ResetNetCache()
+	return p
+}
+
+// listProcesses returns the ids of the user space processes except the current one.
+func listProcesses() ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	self := os.Getpid()
+	pids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid <= 0 || pid == self {
+			continue
+		}
+		// The kernel threads have no executables.
+		if _, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// processSweep is the state of a ScanProcesses call.
+type processSweep struct {
+	c     *Compiled
+	ctx   context.Context
+	opts  ProcessSweepOptions
+	sctx  variables.ScanContextImpl
+	files map[fileID]*FileResult
+	// mntNS is the mount namespace of the current process, and packages are the package databases of the other mount
+	// namespaces.
+	mntNS    string
+	packages map[string]*variables.PackageDB
+}
+
+// scanProcess scans the memory and the backing binaries of the given process.
+func (s *processSweep) scanProcess(pid int) *ProcessResult {
+	res := &ProcessResult{Pid: pid}
+	dir := fmt.Sprintf("/proc/%d", pid)
+	exe, err := os.Readlink(dir + "/exe")
+	if err != nil {
+		res.Err = procScanError(err, pid)
+		return res
+	}
+	// The deleted executables can only be read through /proc/<pid>/exe.
+	res.Path = exe
+	exePath := dir + "/root" + exe
+	if p, ok := variables.DeletedLink(dir + "/exe"); ok {
+		res.Path, exePath = p, dir+"/exe"
+	}
+
+	s.resetContext()
+	s.sctx.SetInProcess(true)
+	s.sctx.SetPid(pid)
+	s.sctx.SetFilePath(res.Path)
+	if proc, err := process.NewProcess(int32(pid)); err == nil {
+		s.sctx.SetProcessInfo(proc)
+	}
+	s.c.SetCallback(&res.Matches)
+	res.Err = s.c.DefineScannerVariables(&s.sctx)
+	if res.Err == nil {
+		res.Err = s.c.ScanProcContext(s.ctx, pid)
+	}
+	if errors.Is(res.Err, ErrCancelled) {
+		return res
+	}
+
+	packages := s.packageDB(pid)
+	res.Exe = s.scanFile(res.Path, exePath, packages)
+
+	regions, err := ProcMemoryRegions(pid)
+	if err != nil {
+		return res
+	}
+	seen := map[string]struct{}{res.Path: {}}
+	for i := range regions {
+		region := &regions[i]
+		if region.Anonymous() || !region.HasPerms("x") || strings.HasSuffix(region.Path, variables.DeletedSuffix) {
+			continue
+		}
+		if _, ok := seen[region.Path]; ok {
+			continue
+		}
+		seen[region.Path] = struct{}{}
+		if err := s.ctx.Err(); err != nil {
+			break
+		}
+		res.Libraries = append(res.Libraries, s.scanFile(region.Path, dir+"/root"+region.Path, packages))
+	}
+	return res
+}
+
+// packageDB returns the package database of the files of the given process. The package databases of the mount
+// namespaces other than the one of the current process are read from the root directory of the first process seen in
+// them.
+func (s *processSweep) packageDB(pid int) *variables.PackageDB {
+	ns, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/mnt", pid))
+	if err != nil || ns == s.mntNS {
+		return s.opts.Packages
+	}
+	if db, ok := s.packages[ns]; ok {
+		return db
+	}
+	db := &variables.PackageDB{Root: fmt.Sprintf("/proc/%d/root", pid)}
+	if p := s.opts.Packages; p != nil {
+		db.DpkgInfoDir, db.RPMExport, db.VerifyDigests = p.DpkgInfoDir, p.RPMExport, p.VerifyDigests
+	}
+	s.packages[ns] = db
+	return db
+}
+
+// scanFile scans the file of the given path read from the given path under /proc, unless it is scanned before in the
+// sweep. The package variables are defined using the given package database.
+func (s *processSweep) scanFile(path, procPath string, packages *variables.PackageDB) *FileResult {
+	info, err := os.Stat(procPath)
+	if err != nil {
+		return &FileResult{Path: path, Err: fileScanError(err, path)}
+	}
+	id, ok := statFileID(info)
+	if ok {
+		if res, ok := s.files[id]; ok {
+			return res
+		}
+	}
+
+	res := &FileResult{Path: path}
+	if ok {
+		s.files[id] = res
+	}
+	s.resetContext()
+	s.sctx.SetInFileSystem(true)
+	s.sctx.SetFilePath(path)
+	s.sctx.SetRealPath(procPath)
+	s.sctx.SetPackageDB(packages)
+	s.sctx.SetFileInfo(info)
+	s.c.SetCallback(&res.Matches)
+	res.Err = s.c.DefineScannerVariables(&s.sctx)
+	if res.Err == nil {
+		res.Err = s.c.scanFile(s.ctx, procPath, &evidenceSource{path: path, file: procPath})
+		var serr *ScanError
+		if errors.As(res.Err, &serr) {
+			serr.Path = path
+		}
+	}
+	return res
+}
+
+func (s *processSweep) resetContext() {
+	s.opts.resetScanContext(&s.sctx)
+	s.sctx.SetProcfs(s.opts.Procfs)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package gora
+
+// ScanProcesses is not supported on this platform.
+func (c *Compiled) ScanProcesses([]int, ProcessSweepOptions, ProcessScanFunc) error {
+	return ErrProcessSweepUnsupported
+}
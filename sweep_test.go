@@ -0,0 +1,76 @@
+package gora_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora"
+)
+
+func TestScanProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process sweep is supported on linux only")
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep is not found")
+	}
+	sleep, err = filepath.EvalSymlinks(sleep)
+	require.NoError(t, err)
+
+	var pids []int
+	for i := 0; i < 2; i++ {
+		cmd := exec.Command(sleep, "30")
+		require.NoError(t, cmd.Start())
+		defer func() {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		}()
+		pids = append(pids, cmd.Process.Pid)
+	}
+
+	comp := gora.NewCompiled()
+	err = comp.CompileString(`
+	rule memory {
+		condition:
+			in_process and process_id > 0
+	}
+	rule elf {
+		condition:
+			in_filesystem and file_name != "" and uint32(0) == 0x464c457f
+	}`, "")
+	require.NoError(t, err)
+	require.NoError(t, comp.CreateScanner())
+	defer comp.Destroy()
+
+	var results []*gora.ProcessResult
+	err = comp.ScanProcesses(pids, gora.ProcessSweepOptions{}, func(res *gora.ProcessResult) error {
+		results = append(results, res)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	for i, res := range results {
+		require.Equal(t, pids[i], res.Pid)
+		require.Equal(t, sleep, res.Path)
+		require.NoError(t, res.Err)
+		require.Len(t, res.Matches, 1)
+		require.Equal(t, "memory", res.Matches[0].Rule)
+
+		require.NotNil(t, res.Exe)
+		require.NoError(t, res.Exe.Err)
+		require.Len(t, res.Exe.Matches, 1)
+		require.Equal(t, "elf", res.Exe.Matches[0].Rule)
+	}
+
+	require.Same(t, results[0].Exe, results[1].Exe)
+	require.Len(t, results[1].Libraries, len(results[0].Libraries))
+	for i, lib := range results[0].Libraries {
+		require.Same(t, lib, results[1].Libraries[i])
+	}
+}
@@ -0,0 +1,146 @@
+package variables
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The revisions and the flags of the security.capability extended attribute, see vfs_cap_data in linux/capability.h.
+const (
+	vfsCapRevisionMask   = 0xff000000
+	vfsCapRevision1      = 0x01000000
+	vfsCapRevision2      = 0x02000000
+	vfsCapRevision3      = 0x03000000
+	vfsCapFlagsEffective = 0x000001
+)
+
+// capNames are the names of the Linux capabilities by their bit numbers, as printed by capsh.
+var capNames = []string{
+	"cap_chown",
+	"cap_dac_override",
+	"cap_dac_read_search",
+	"cap_fowner",
+	"cap_fsetid",
+	"cap_kill",
+	"cap_setgid",
+	"cap_setuid",
+	"cap_setpcap",
+	"cap_linux_immutable",
+	"cap_net_bind_service",
+	"cap_net_broadcast",
+	"cap_net_admin",
+	"cap_net_raw",
+	"cap_ipc_lock",
+	"cap_ipc_owner",
+	"cap_sys_module",
+	"cap_sys_rawio",
+	"cap_sys_chroot",
+	"cap_sys_ptrace",
+	"cap_sys_pacct",
+	"cap_sys_admin",
+	"cap_sys_boot",
+	"cap_sys_nice",
+	"cap_sys_resource",
+	"cap_sys_time",
+	"cap_sys_tty_config",
+	"cap_mknod",
+	"cap_lease",
+	"cap_audit_write",
+	"cap_audit_control",
+	"cap_setfcap",
+	"cap_mac_override",
+	"cap_mac_admin",
+	"cap_syslog",
+	"cap_wake_alarm",
+	"cap_block_suspend",
+	"cap_audit_read",
+	"cap_perfmon",
+	"cap_bpf",
+	"cap_checkpoint_restore",
+}
+
+// capabilityNames returns the comma separated names of the capabilities in the given set.
+func capabilityNames(set uint64) string {
+	var names []string
+	for bit := 0; bit < 64; bit++ {
+		if set&(1<<bit) != 0 {
+			names = append(names, capName(bit))
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// capName returns the name of the given capability. The capabilities unknown to gora are named by their bit numbers,
+// such as "cap_41".
+func capName(bit int) string {
+	if bit < len(capNames) {
+		return capNames[bit]
+	}
+	return "cap_" + strconv.Itoa(bit)
+}
+
+// fileCapabilities decodes the given security.capability extended attribute into the text form printed by getcap, such
+// as "cap_net_admin,cap_net_raw=ep".
+func fileCapabilities(data []byte) (string, error) {
+	if len(data) < 4 {
+		return "", fmt.Errorf("invalid file capabilities of %d bytes", len(data))
+	}
+	magic := binary.LittleEndian.Uint32(data)
+	words := 0
+	switch magic & vfsCapRevisionMask {
+	case vfsCapRevision1:
+		words = 1
+	case vfsCapRevision2, vfsCapRevision3:
+		words = 2
+	default:
+		return "", fmt.Errorf("unknown file capabilities revision %#x", magic&vfsCapRevisionMask)
+	}
+	if len(data) < 4+8*words {
+		return "", fmt.Errorf("invalid file capabilities of %d bytes", len(data))
+	}
+
+	// The sets are stored as 32-bit words, the permitted and the inheritable words in turn.
+	var permitted, inheritable uint64
+	for i := 0; i < words; i++ {
+		permitted |= uint64(binary.LittleEndian.Uint32(data[4+8*i:])) << (32 * i)
+		inheritable |= uint64(binary.LittleEndian.Uint32(data[8+8*i:])) << (32 * i)
+	}
+	return capabilityText(permitted, inheritable, magic&vfsCapFlagsEffective != 0), nil
+}
+
+// capabilityText returns the text form of the given file capability sets. The capabilities with the same flags are
+// grouped, such as "cap_chown,cap_kill=ep cap_net_raw=i".
+func capabilityText(permitted, inheritable uint64, effective bool) string {
+	var (
+		order  []string
+		groups = make(map[string][]string)
+	)
+	for bit := 0; bit < 64; bit++ {
+		p, i := permitted&(1<<bit) != 0, inheritable&(1<<bit) != 0
+		if !p && !i {
+			continue
+		}
+		var flags string
+		if effective {
+			flags += "e"
+		}
+		if i {
+			flags += "i"
+		}
+		if p {
+			flags += "p"
+		}
+		if _, ok := groups[flags]; !ok {
+			order = append(order, flags)
+		}
+		groups[flags] = append(groups[flags], capName(bit))
+	}
+
+	text := make([]string, 0, len(order))
+	for _, flags := range order {
+		text = append(text, strings.Join(groups[flags], ",")+"="+flags)
+	}
+	return strings.Join(text, " ")
+}
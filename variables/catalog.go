@@ -0,0 +1,152 @@
+package variables
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownVariable is returned when a variable name cannot be found.
+var ErrUnknownVariable = errors.New("unknown variable")
+
+// VariableInfo describes an external variable.
+type VariableInfo struct {
+	Variable    VariableType `json:"-"`
+	Name        string       `json:"name"`
+	Type        MetaType     `json:"type"`
+	Default     interface{}  `json:"default"`
+	Description string       `json:"description"`
+	OS          []string     `json:"os"`
+	OptIn       bool         `json:"opt_in"`
+}
+
+// osNames holds the names of the operating systems in the order of OSType bits.
+var osNames = []struct {
+	os   OSType
+	name string
+}{
+	{OSLinux, "linux"},
+	{OSWindows, "windows"},
+	{OSDarwin, "darwin"},
+	{OSAIX, "aix"},
+}
+
+// Lookup returns the VariableType of the given variable name. It returns false as second value if there is no such
+// variable.
+func Lookup(name string) (VariableType, bool) {
+	for i, n := range varNames {
+		if i > 0 && n == name {
+			return VariableType(i), true
+		}
+	}
+	return 0, false
+}
+
+// Describe returns the description of the given variable. It returns false as second value if the variable is
+// unknown.
+func Describe(v VariableType) (VariableInfo, bool) {
+	if v == 0 || v >= typeEnd {
+		return VariableInfo{}, false
+	}
+	return VariableInfo{
+		Variable:    v,
+		Name:        v.String(),
+		Type:        v.Meta(),
+		Default:     defaultValue(v),
+		Description: varDescriptions[v],
+		OS:          v.OS().Names(),
+		OptIn:       v.OptIn(),
+	}, true
+}
+
+// Catalog returns the descriptions of all available variables in the order of List.
+func Catalog() []VariableInfo {
+	vars := List()
+	catalog := make([]VariableInfo, 0, len(vars))
+	for _, v := range vars {
+		info, _ := Describe(v)
+		catalog = append(catalog, info)
+	}
+	return catalog
+}
+
+// OS returns the operating systems supported by the variable.
+func (v VariableType) OS() OSType {
+	if v < typeEnd {
+		return varOSes[v]
+	}
+	return 0
+}
+
+// MarshalText implements the encoding.TextMarshaler interface. VariableType is marshaled as its name.
+func (v VariableType) MarshalText() ([]byte, error) {
+	if v == 0 || v >= typeEnd {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVariable, v)
+	}
+	return []byte(v.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface. VariableType is unmarshaled from its name.
+func (v *VariableType) UnmarshalText(text []byte) error {
+	vid, ok := Lookup(string(text))
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownVariable, text)
+	}
+	*v = vid
+	return nil
+}
+
+// String implements the fmt.Stringer interface and returns the name of the value type, such as String or Boolean.
+func (m MetaType) String() string {
+	switch {
+	case m&MetaString != 0:
+		return "String"
+	case m&MetaInt != 0:
+		return "Integer"
+	case m&MetaBool != 0:
+		return "Boolean"
+	case m&MetaFloat != 0:
+		return "Float"
+	}
+	return ""
+}
+
+// MarshalText implements the encoding.TextMarshaler interface. MetaType is marshaled as its name.
+func (m MetaType) MarshalText() ([]byte, error) {
+	s := m.String()
+	if s == "" {
+		return nil, fmt.Errorf("unknown meta type: %d", m)
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface. MetaType is unmarshaled from its name.
+func (m *MetaType) UnmarshalText(text []byte) error {
+	for _, meta := range []MetaType{MetaBool, MetaInt, MetaFloat, MetaString} {
+		if meta.String() == string(text) {
+			*m = meta
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown meta type: %q", text)
+}
+
+// Names returns the names of the operating systems, such as linux or windows.
+func (o OSType) Names() []string {
+	names := make([]string, 0, len(osNames))
+	for _, n := range osNames {
+		if o&n.os != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
+
+// Supports reports whether the given GOOS value is one of the operating systems.
+func (o OSType) Supports(goos string) bool {
+	for _, n := range osNames {
+		if n.name == goos {
+			return o&n.os != 0
+		}
+	}
+	return false
+}
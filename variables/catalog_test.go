@@ -0,0 +1,86 @@
+package variables_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/binalyze/gora/variables"
+)
+
+func TestLookup(t *testing.T) {
+	for _, v := range List() {
+		got, ok := Lookup(v.String())
+		require.True(t, ok)
+		require.Equal(t, v, got)
+	}
+
+	_, ok := Lookup("")
+	require.False(t, ok)
+	_, ok = Lookup("no_such_variable")
+	require.False(t, ok)
+}
+
+func TestDescribe(t *testing.T) {
+	info, ok := Describe(VarFileSystem)
+	require.True(t, ok)
+	require.Equal(t, VariableInfo{
+		Variable:    VarFileSystem,
+		Name:        "file_system",
+		Type:        MetaBool,
+		Default:     false,
+		Description: "If it is a system file, its value is true",
+		OS:          []string{"windows"},
+	}, info)
+
+	info, ok = Describe(VarFileChangedTime)
+	require.True(t, ok)
+	require.Equal(t, int64(0), info.Default)
+	require.Equal(t, []string{"linux", "darwin", "aix"}, info.OS)
+	require.True(t, VarFileChangedTime.OS().Supports("linux"))
+	require.False(t, VarFileChangedTime.OS().Supports("windows"))
+
+	_, ok = Describe(0)
+	require.False(t, ok)
+}
+
+func TestCatalog(t *testing.T) {
+	catalog := Catalog()
+	require.Len(t, catalog, len(List()))
+	for _, info := range catalog {
+		require.NotEmpty(t, info.Name)
+		require.NotEmpty(t, info.Description)
+		require.NotEmpty(t, info.OS)
+		require.NotNil(t, info.Default)
+	}
+
+	b, err := json.Marshal(catalog[0])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"os","type":"String","default":"","description":"Operating system name, linux, windows, darwin or aix","os":["linux","windows","darwin","aix"],"opt_in":false}`, string(b))
+}
+
+func TestVariableType_MarshalText(t *testing.T) {
+	type config struct {
+		Vars []VariableType `json:"vars"`
+	}
+
+	b, err := json.Marshal(config{Vars: []VariableType{VarFilePath, VarProcessName}})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"vars":["file_path","process_name"]}`, string(b))
+
+	var c config
+	require.NoError(t, json.Unmarshal(b, &c))
+	require.Equal(t, []VariableType{VarFilePath, VarProcessName}, c.Vars)
+
+	err = json.Unmarshal([]byte(`{"vars":["unknown"]}`), &c)
+	require.True(t, errors.Is(err, ErrUnknownVariable))
+
+	_, err = json.Marshal(config{Vars: []VariableType{0}})
+	require.Error(t, err)
+
+	var m MetaType
+	require.NoError(t, m.UnmarshalText([]byte("Integer")))
+	require.Equal(t, MetaInt, m)
+}
@@ -11,25 +11,83 @@ type ScanContextImpl struct {
 	ctx          context.Context
 	finfo        fs.FileInfo
 	fpath        string
+	realPath     string
+	realPathSet  bool
+	pathMapping  *PathMapping
+	region       *MemoryRegion
+	procfs       *Procfs
+	mounts       *MountTable
+	packages     *PackageDB
 	pid          int
 	proc         ProcessInfo
 	inProcess    bool
 	inFileSystem bool
 	valErrFn     func(VariableDefiner, VariableType, error) error
-}
+	summary      ValueSummary
+}
+
+var (
+	_ ScanContext          = (*ScanContextImpl)(nil)
+	_ DefaultRecorder      = (*ScanContextImpl)(nil)
+	_ PathMapper           = (*ScanContextImpl)(nil)
+	_ RealPathProvider     = (*ScanContextImpl)(nil)
+	_ MemoryRegionProvider = (*ScanContextImpl)(nil)
+	_ ProcfsProvider       = (*ScanContextImpl)(nil)
+	_ MountTableProvider   = (*ScanContextImpl)(nil)
+	_ PackageDBProvider    = (*ScanContextImpl)(nil)
+)
 
-var _ ScanContext = (*ScanContextImpl)(nil)
+// snapshotScanContext returns a ScanContextImpl holding the current values of the given scan context and of its
+// optional provider interfaces. The Valuers run in the background use it since the abandoned ones may still be running
+// when the scan context is reset for the next scan target. The value error handler is not copied.
+func snapshotScanContext(sCtx ScanContext) *ScanContextImpl {
+	snap := &ScanContextImpl{
+		ctx:          sCtx.Context(),
+		finfo:        sCtx.FileInfo(),
+		fpath:        sCtx.FilePath(),
+		realPath:     realFilePath(sCtx),
+		realPathSet:  true,
+		pid:          sCtx.Pid(),
+		proc:         sCtx.ProcessInfo(),
+		inProcess:    sCtx.InProcess(),
+		inFileSystem: sCtx.InFileSystem(),
+	}
+	if p, ok := sCtx.(PathMapper); ok {
+		snap.pathMapping = p.PathMapping()
+	}
+	if p, ok := sCtx.(MemoryRegionProvider); ok {
+		snap.region = p.MemoryRegion()
+	}
+	if p, ok := sCtx.(ProcfsProvider); ok {
+		snap.procfs = p.Procfs()
+	}
+	if p, ok := sCtx.(MountTableProvider); ok {
+		snap.mounts = p.MountTable()
+	}
+	if p, ok := sCtx.(PackageDBProvider); ok {
+		snap.packages = p.PackageDB()
+	}
+	return snap
+}
 
 // Reset resets all the fields to be able to reuse the same ScanContextImpl instance.
 func (sc *ScanContextImpl) Reset() {
 	sc.ctx = nil
 	sc.finfo = nil
 	sc.fpath = ""
+	sc.realPath = ""
+	sc.realPathSet = false
+	sc.pathMapping = nil
+	sc.region = nil
+	sc.procfs = nil
+	sc.mounts = nil
+	sc.packages = nil
 	sc.pid = 0
 	sc.proc = nil
 	sc.valErrFn = nil
 	sc.inProcess = false
 	sc.inFileSystem = false
+	sc.summary.Reset()
 }
 
 // Context is to implement the ScanContext interface. It returns context.Background() if underlying context is missing.
@@ -65,6 +123,72 @@ func (sc *ScanContextImpl) SetFilePath(p string) {
 	sc.fpath = p
 }
 
+// RealPath is to implement the RealPathProvider interface. It returns the file path unless SetRealPath is called.
+func (sc *ScanContextImpl) RealPath() string {
+	if !sc.realPathSet {
+		return sc.fpath
+	}
+	return sc.realPath
+}
+
+// SetRealPath sets the path the file is read from on the host when it differs from the file path, such as
+// /proc/<pid>/fd/3. An empty path means the file cannot be read from the host.
+func (sc *ScanContextImpl) SetRealPath(p string) {
+	sc.realPath = p
+	sc.realPathSet = true
+}
+
+// PathMapping is to implement the PathMapper interface.
+func (sc *ScanContextImpl) PathMapping() *PathMapping {
+	return sc.pathMapping
+}
+
+// SetPathMapping sets the path mapping to define the path based variables using the original paths of the files in a
+// mounted evidence. The file path set by SetFilePath must be the real path.
+func (sc *ScanContextImpl) SetPathMapping(m *PathMapping) {
+	sc.pathMapping = m
+}
+
+// MemoryRegion is to implement the MemoryRegionProvider interface.
+func (sc *ScanContextImpl) MemoryRegion() *MemoryRegion {
+	return sc.region
+}
+
+// SetMemoryRegion sets the memory region to be returned from MemoryRegion method.
+func (sc *ScanContextImpl) SetMemoryRegion(r *MemoryRegion) {
+	sc.region = r
+}
+
+// Procfs is to implement the ProcfsProvider interface.
+func (sc *ScanContextImpl) Procfs() *Procfs {
+	return sc.procfs
+}
+
+// SetProcfs sets the procfs settings to be returned from Procfs method.
+func (sc *ScanContextImpl) SetProcfs(p *Procfs) {
+	sc.procfs = p
+}
+
+// MountTable is to implement the MountTableProvider interface.
+func (sc *ScanContextImpl) MountTable() *MountTable {
+	return sc.mounts
+}
+
+// SetMountTable sets the mount table to be returned from MountTable method.
+func (sc *ScanContextImpl) SetMountTable(t *MountTable) {
+	sc.mounts = t
+}
+
+// PackageDB is to implement the PackageDBProvider interface.
+func (sc *ScanContextImpl) PackageDB() *PackageDB {
+	return sc.packages
+}
+
+// SetPackageDB sets the package database to be returned from PackageDB method.
+func (sc *ScanContextImpl) SetPackageDB(db *PackageDB) {
+	sc.packages = db
+}
+
 // SetInFileSystem sets file system context flag
 func (sc *ScanContextImpl) SetInFileSystem(v bool) {
 	sc.inFileSystem = v
@@ -94,11 +218,22 @@ func (sc *ScanContextImpl) HandleValueError(d VariableDefiner, v VariableType, e
 	return sc.valErrFn(d, v, err)
 }
 
-// SetHandleValueError sets the underlying value error handler.
+// SetHandleValueError sets the underlying value error handler. See IgnoreValueErrors, LogValueErrors,
+// FailOnValueErrors and ValueErrorCollector for the built-in handlers.
 func (sc *ScanContextImpl) SetHandleValueError(fn func(VariableDefiner, VariableType, error) error) {
 	sc.valErrFn = fn
 }
 
+// RecordDefault is to implement the DefaultRecorder interface.
+func (sc *ScanContextImpl) RecordDefault(v VariableType, err error) {
+	sc.summary.RecordDefault(v, err)
+}
+
+// ValueSummary returns the summary of the variables defined with their default values since the last Reset.
+func (sc *ScanContextImpl) ValueSummary() ValueSummary {
+	return sc.summary.Copy()
+}
+
 // Pid is to implement the ScanContext interface.
 func (sc *ScanContextImpl) Pid() int {
 	return sc.pid
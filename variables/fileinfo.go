@@ -0,0 +1,73 @@
+package variables
+
+import (
+	"io/fs"
+	"time"
+
+	"github.com/djherbis/times"
+)
+
+// VirtualFileInfo is an fs.FileInfo for the scan targets which are not in the file system, such as in-memory buffers,
+// streams and archive members. It is set to the ScanContext with a virtual file path to define the file variables.
+//
+// It implements times.Timespec to provide the file times other than the modification time. The zero times are
+// reported as missing, and their variables are defined with the default values.
+type VirtualFileInfo struct {
+	FileName     string
+	FileSize     int64
+	FileMode     fs.FileMode
+	ModifiedTime time.Time
+	AccessedTime time.Time
+	ChangedTime  time.Time
+	CreatedTime  time.Time
+}
+
+var (
+	_ fs.FileInfo    = (*VirtualFileInfo)(nil)
+	_ times.Timespec = (*VirtualFileInfo)(nil)
+)
+
+// Name implements the fs.FileInfo interface.
+func (fi *VirtualFileInfo) Name() string { return fi.FileName }
+
+// Size implements the fs.FileInfo interface.
+func (fi *VirtualFileInfo) Size() int64 { return fi.FileSize }
+
+// Mode implements the fs.FileInfo interface.
+func (fi *VirtualFileInfo) Mode() fs.FileMode { return fi.FileMode }
+
+// ModTime implements the fs.FileInfo and times.Timespec interfaces.
+func (fi *VirtualFileInfo) ModTime() time.Time { return fi.ModifiedTime }
+
+// IsDir implements the fs.FileInfo interface.
+func (fi *VirtualFileInfo) IsDir() bool { return fi.FileMode.IsDir() }
+
+// Sys implements the fs.FileInfo interface. It returns nil.
+func (fi *VirtualFileInfo) Sys() interface{} { return nil }
+
+// AccessTime implements the times.Timespec interface.
+func (fi *VirtualFileInfo) AccessTime() time.Time { return fi.AccessedTime }
+
+// ChangeTime implements the times.Timespec interface.
+func (fi *VirtualFileInfo) ChangeTime() time.Time { return fi.ChangedTime }
+
+// BirthTime implements the times.Timespec interface.
+func (fi *VirtualFileInfo) BirthTime() time.Time { return fi.CreatedTime }
+
+// HasChangeTime implements the times.Timespec interface.
+func (fi *VirtualFileInfo) HasChangeTime() bool { return !fi.ChangedTime.IsZero() }
+
+// HasBirthTime implements the times.Timespec interface.
+func (fi *VirtualFileInfo) HasBirthTime() bool { return !fi.CreatedTime.IsZero() }
+
+// fileTimes returns the file times of the given file info. It returns nil if the file info is neither from the file
+// system nor implements times.Timespec.
+func fileTimes(info fs.FileInfo) times.Timespec {
+	if ts, ok := info.(times.Timespec); ok {
+		return ts
+	}
+	if !isStatInfo(info) {
+		return nil
+	}
+	return times.Get(info)
+}
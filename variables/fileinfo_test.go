@@ -0,0 +1,43 @@
+package variables_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/binalyze/gora/variables"
+)
+
+func TestVirtualFileInfo(t *testing.T) {
+	now := time.Now()
+	info := &VirtualFileInfo{
+		FileName:     "x.js",
+		FileSize:     10,
+		ModifiedTime: now,
+		AccessedTime: now,
+	}
+	require.Equal(t, "x.js", info.Name())
+	require.EqualValues(t, 10, info.Size())
+	require.False(t, info.IsDir())
+	require.Nil(t, info.Sys())
+
+	var sctx ScanContextImpl
+	sctx.SetFilePath("/a.zip!/x.js")
+	sctx.SetFileInfo(info)
+
+	for _, v := range []VariableType{VarFileModifiedTime, VarFileAccessedTime} {
+		value, err := Valuers[v].Value(&sctx)
+		require.NoError(t, err)
+		require.NotZero(t, value)
+	}
+	for _, v := range []VariableType{VarFileChangedTime, VarFileBirthTime} {
+		value, err := Valuers[v].Value(&sctx)
+		require.NoError(t, err)
+		require.Nil(t, value)
+	}
+
+	value, err := Valuers[VarFileExtension].Value(&sctx)
+	require.NoError(t, err)
+	require.Equal(t, "js", value)
+}
@@ -0,0 +1,114 @@
+package variables
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type (
+	// MemoryRegion is a mapped memory region of a process, as listed in /proc/<pid>/maps on Linux.
+	MemoryRegion struct {
+		// Base is the start address of the region.
+		Base uint64 `json:"base"`
+		// Size is the size of the region in bytes.
+		Size uint64 `json:"size"`
+		// Perms are the permissions of the region, such as "r-xp". The last character is "p" for the private and "s"
+		// for the shared mappings.
+		Perms string `json:"perms"`
+		// Offset is the offset of the region in its backing file.
+		Offset uint64 `json:"offset"`
+		// Inode is the inode of the backing file, or zero if there is none.
+		Inode uint64 `json:"inode"`
+		// Path is the path of the backing file, a pseudo path such as "[heap]" or "[stack]", or empty.
+		Path string `json:"path"`
+	}
+
+	// MemoryRegionProvider is an optional interface for the ScanContext implementations to define the region
+	// variables, such as region_path and region_perms, of the memory region being scanned. ScanContextImpl implements
+	// it.
+	MemoryRegionProvider interface {
+		MemoryRegion() *MemoryRegion
+	}
+)
+
+// Anonymous reports whether the region is not backed by a file. The pseudo paths such as "[heap]" and "[stack]" are
+// anonymous.
+func (r *MemoryRegion) Anonymous() bool {
+	return r.Inode == 0 && !strings.HasPrefix(r.Path, "/")
+}
+
+// HasPerms reports whether the region has all the given permissions, such as "x" or "rwx".
+func (r *MemoryRegion) HasPerms(perms string) bool {
+	for _, p := range perms {
+		if p == '-' || !strings.ContainsRune(r.Perms, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// memoryRegion returns the memory region of the given scan context, or nil if it does not provide one.
+func memoryRegion(sCtx ScanContext) *MemoryRegion {
+	if p, ok := sCtx.(MemoryRegionProvider); ok {
+		return p.MemoryRegion()
+	}
+	return nil
+}
+
+// ParseMemoryMaps parses the memory regions in the format of /proc/<pid>/maps.
+func ParseMemoryMaps(r io.Reader) ([]MemoryRegion, error) {
+	var regions []MemoryRegion
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if s.Text() == "" {
+			continue
+		}
+		region, err := parseMemoryMapsLine(s.Text())
+		if err != nil {
+			return nil, err
+		}
+		regions = append(regions, region)
+	}
+	return regions, s.Err()
+}
+
+// parseMemoryMapsLine parses a line of /proc/<pid>/maps, such as:
+//
+//	7f3c1a2b1000-7f3c1a2d3000 r-xp 00002000 fd:01 1835023                    /usr/lib/x86_64-linux-gnu/ld-linux.so.2
+func parseMemoryMapsLine(line string) (MemoryRegion, error) {
+	var fields [5]string
+	rest := line
+	for i := range fields {
+		rest = strings.TrimLeft(rest, " ")
+		n := strings.IndexByte(rest, ' ')
+		if n < 0 {
+			n = len(rest)
+		}
+		fields[i], rest = rest[:n], rest[n:]
+	}
+
+	var region MemoryRegion
+	start, end, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return region, fmt.Errorf("invalid memory map line: %q", line)
+	}
+	var (
+		endAddr uint64
+		errs    [4]error
+	)
+	region.Base, errs[0] = strconv.ParseUint(start, 16, 64)
+	endAddr, errs[1] = strconv.ParseUint(end, 16, 64)
+	region.Offset, errs[2] = strconv.ParseUint(fields[2], 16, 64)
+	region.Inode, errs[3] = strconv.ParseUint(fields[4], 10, 64)
+	if err := errors.Join(errs[:]...); err != nil || endAddr < region.Base || fields[1] == "" {
+		return region, fmt.Errorf("invalid memory map line: %q", line)
+	}
+	region.Size = endAddr - region.Base
+	region.Perms = fields[1]
+	region.Path = strings.TrimLeft(rest, " ")
+	return region, nil
+}
@@ -0,0 +1,48 @@
+package variables_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/binalyze/gora/variables"
+)
+
+func TestMemoryRegion(t *testing.T) {
+	heap := &MemoryRegion{Base: 0x1000, Size: 0x2000, Perms: "rwxp", Path: "[heap]"}
+	require.True(t, heap.Anonymous())
+	require.True(t, heap.HasPerms("rwx"))
+	require.True(t, heap.HasPerms(""))
+
+	lib := &MemoryRegion{Base: 0x8000, Size: 0x1000, Perms: "r-xp", Inode: 42, Path: "/usr/lib/libc.so.6"}
+	require.False(t, lib.Anonymous())
+	require.True(t, lib.HasPerms("rx"))
+	require.False(t, lib.HasPerms("w"))
+	require.False(t, lib.HasPerms("-"))
+}
+
+func TestMemoryRegionVariables(t *testing.T) {
+	var sctx ScanContextImpl
+	for _, v := range []VariableType{VarRegionPath, VarRegionPerms, VarRegionAnonymous, VarRegionBase, VarRegionSize} {
+		value, err := Valuers[v].Value(&sctx)
+		require.NoError(t, err)
+		require.Nil(t, value)
+	}
+
+	sctx.SetMemoryRegion(&MemoryRegion{Base: 0x1000, Size: 0x2000, Perms: "rwxp", Path: "[heap]"})
+	want := map[VariableType]interface{}{
+		VarRegionPath:      "[heap]",
+		VarRegionPerms:     "rwxp",
+		VarRegionAnonymous: true,
+		VarRegionBase:      int64(0x1000),
+		VarRegionSize:      int64(0x2000),
+	}
+	for v, w := range want {
+		value, err := Valuers[v].Value(&sctx)
+		require.NoError(t, err)
+		require.Equal(t, w, value, v.String())
+	}
+
+	sctx.Reset()
+	require.Nil(t, sctx.MemoryRegion())
+}
@@ -0,0 +1,295 @@
+package variables
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMountInfo is the mount table read if MountTable.Path is empty.
+	DefaultMountInfo = "/proc/self/mountinfo"
+	// DefaultMountMaxAge is the duration the mounts are cached for if MountTable.MaxAge is zero.
+	DefaultMountMaxAge = time.Minute
+)
+
+// networkFSTypes are the file system types of the network file systems. The FUSE file systems are matched by their
+// subtypes, such as "sshfs" of "fuse.sshfs".
+var networkFSTypes = map[string]struct{}{
+	"9p":        {},
+	"afs":       {},
+	"ceph":      {},
+	"cifs":      {},
+	"davfs":     {},
+	"glusterfs": {},
+	"lustre":    {},
+	"ncpfs":     {},
+	"nfs":       {},
+	"nfs4":      {},
+	"smb3":      {},
+	"smbfs":     {},
+	"sshfs":     {},
+}
+
+// defaultMountTable is the mount table of the scan contexts not providing one.
+var defaultMountTable = &MountTable{}
+
+type (
+	// Mount is a mount of the mount table, as listed in /proc/self/mountinfo on Linux.
+	Mount struct {
+		ID       int `json:"id"`
+		ParentID int `json:"parent_id"`
+		// Major and Minor are the device numbers of the file system.
+		Major uint32 `json:"major"`
+		Minor uint32 `json:"minor"`
+		// Root is the directory of the file system mounted, such as "/" or the source of a bind mount.
+		Root string `json:"root"`
+		// Point is the mount point.
+		Point string `json:"point"`
+		// Options are the mount options, such as "rw" and "noexec".
+		Options []string `json:"options"`
+		// FSType is the file system type, such as "ext4", "tmpfs" or "fuse.sshfs".
+		FSType string `json:"fs_type"`
+		// Source is the mounted device or the file system specific source, such as "server:/export".
+		Source string `json:"source"`
+		// SuperOptions are the options of the file system.
+		SuperOptions []string `json:"super_options"`
+	}
+
+	// MountTable is the cached mount table of the file system variables, such as file_fs_type and file_mount_point,
+	// read from /proc/self/mountinfo on Linux. The mounts are read again when they are older than MaxAge, or after
+	// Invalidate. It is safe for concurrent use.
+	MountTable struct {
+		// Path is the path of the mountinfo file. DefaultMountInfo is used if it is empty.
+		Path string
+		// MaxAge is the duration the mounts are cached for. DefaultMountMaxAge is used if it is zero.
+		MaxAge time.Duration
+
+		mu     sync.Mutex
+		mounts []Mount
+		readAt time.Time
+	}
+
+	// MountTableProvider is an optional interface for the ScanContext implementations to set the mount table of the
+	// file system variables. ScanContextImpl implements it.
+	MountTableProvider interface {
+		MountTable() *MountTable
+	}
+)
+
+// mountTable returns the mount table of the given scan context. A mount table shared by the scan contexts is returned
+// if the scan context does not provide one.
+func mountTable(sCtx ScanContext) *MountTable {
+	if p, ok := sCtx.(MountTableProvider); ok {
+		if t := p.MountTable(); t != nil {
+			return t
+		}
+	}
+	return defaultMountTable
+}
+
+// Mounts returns the mounts in the order of the mount table. They are read if they are not cached or they are
+// expired. The returned slice must not be modified.
+func (t *MountTable) Mounts() ([]Mount, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	maxAge := t.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultMountMaxAge
+	}
+	if t.mounts != nil && time.Since(t.readAt) < maxAge {
+		return t.mounts, nil
+	}
+
+	name := t.Path
+	if name == "" {
+		name = DefaultMountInfo
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint errcheck
+
+	mounts, err := ParseMountInfo(f)
+	if err != nil {
+		return nil, err
+	}
+	t.mounts, t.readAt = mounts, time.Now()
+	return mounts, nil
+}
+
+// Invalidate removes the cached mounts, so they are read again.
+func (t *MountTable) Invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mounts = nil
+}
+
+// Lookup returns the mount of the given path, which is the mount with the longest mount point containing the path. Of
+// the mounts stacked on the same mount point, the last one is returned since it hides the others. The relative paths
+// are resolved from the working directory, but the symbolic links are not resolved. It returns nil if no mount
+// contains the path.
+func (t *MountTable) Lookup(path string) (*Mount, error) {
+	mounts, err := t.Mounts()
+	if err != nil {
+		return nil, err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return nil, err
+	}
+
+	var found *Mount
+	for i := range mounts {
+		m := &mounts[i]
+		if !m.Contains(path) {
+			continue
+		}
+		if found == nil || len(m.Point) >= len(found.Point) {
+			found = m
+		}
+	}
+	return found, nil
+}
+
+// lookupDevice returns the mount of the file system with the given device numbers, used for the files read through
+// procfs, such as /proc/<pid>/fd/3, whose paths are not in their file systems. Of the mounts of the same device, such
+// as the bind mounts, the one with the longest mount point containing the given path is returned, or the first one if
+// none contains it. It returns nil if no mount has the device.
+func (t *MountTable) lookupDevice(major, minor uint32, path string) (*Mount, error) {
+	mounts, err := t.Mounts()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Mount
+	for i := range mounts {
+		m := &mounts[i]
+		if m.Major != major || m.Minor != minor {
+			continue
+		}
+		switch {
+		case found == nil:
+			found = m
+		case m.Contains(path) && (!found.Contains(path) || len(m.Point) >= len(found.Point)):
+			found = m
+		}
+	}
+	return found, nil
+}
+
+// Contains reports whether the given absolute path is under the mount point.
+func (m *Mount) Contains(path string) bool {
+	return m.Point == "/" || path == m.Point || strings.HasPrefix(path, m.Point+"/")
+}
+
+// HasOption reports whether the given mount option, such as "noexec", is set.
+func (m *Mount) HasOption(option string) bool {
+	for _, o := range m.Options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Network reports whether the mount is of a network file system, such as NFS, CIFS or sshfs.
+func (m *Mount) Network() bool {
+	fsType := m.FSType
+	if subtype, ok := strings.CutPrefix(fsType, "fuse."); ok {
+		fsType = subtype
+	}
+	_, ok := networkFSTypes[fsType]
+	return ok
+}
+
+// ParseMountInfo parses the mounts in the format of /proc/<pid>/mountinfo on Linux.
+func ParseMountInfo(r io.Reader) ([]Mount, error) {
+	mounts := []Mount{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+		m, err := parseMountInfoLine(line)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, sc.Err()
+}
+
+// parseMountInfoLine parses a line of mountinfo, such as
+// "36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue". The optional fields before the
+// "-" separator are skipped.
+func parseMountInfoLine(line string) (Mount, error) {
+	fields := strings.Fields(line)
+	sep := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || len(fields) < sep+3 {
+		return Mount{}, fmt.Errorf("invalid mountinfo line %q", line)
+	}
+
+	var (
+		m            Mount
+		major, minor uint64
+		errs         [4]error
+	)
+	m.ID, errs[0] = strconv.Atoi(fields[0])
+	m.ParentID, errs[1] = strconv.Atoi(fields[1])
+	majorMinor := strings.SplitN(fields[2], ":", 2)
+	if len(majorMinor) != 2 {
+		return Mount{}, fmt.Errorf("invalid mountinfo device %q", fields[2])
+	}
+	major, errs[2] = strconv.ParseUint(majorMinor[0], 10, 32)
+	minor, errs[3] = strconv.ParseUint(majorMinor[1], 10, 32)
+	for _, err := range errs {
+		if err != nil {
+			return Mount{}, fmt.Errorf("invalid mountinfo line %q: %w", line, err)
+		}
+	}
+	m.Major, m.Minor = uint32(major), uint32(minor)
+	m.Root = unescapeMountInfo(fields[3])
+	m.Point = unescapeMountInfo(fields[4])
+	m.Options = strings.Split(fields[5], ",")
+	m.FSType = fields[sep+1]
+	m.Source = unescapeMountInfo(fields[sep+2])
+	if len(fields) > sep+3 {
+		m.SuperOptions = strings.Split(fields[sep+3], ",")
+	}
+	return m, nil
+}
+
+// unescapeMountInfo replaces the octal escapes of the white space and the backslash characters in a field of
+// mountinfo, such as "\040" for a space.
+func unescapeMountInfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,48 @@
+package variables_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/binalyze/gora/variables"
+)
+
+const testMountInfo = `1 0 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+2 1 0:20 / /dev/shm rw,nosuid,nodev,noexec shared:2 - tmpfs tmpfs rw,size=65536k
+3 1 0:50 / /mnt/my\040share rw master:1 propagate_from:1 - cifs //server/share rw
+4 1 0:51 / /mnt/ssh rw - fuse.sshfs user@host: rw
+5 2 0:52 / /dev/shm rw,nosuid - tmpfs tmpfs rw
+`
+
+func TestParseMountInfo(t *testing.T) {
+	mounts, err := ParseMountInfo(strings.NewReader(testMountInfo))
+	require.NoError(t, err)
+	require.Len(t, mounts, 5)
+	require.Equal(t, Mount{
+		ID:           2,
+		ParentID:     1,
+		Minor:        20,
+		Root:         "/",
+		Point:        "/dev/shm",
+		Options:      []string{"rw", "nosuid", "nodev", "noexec"},
+		FSType:       "tmpfs",
+		Source:       "tmpfs",
+		SuperOptions: []string{"rw", "size=65536k"},
+	}, mounts[1])
+	require.True(t, mounts[1].HasOption("noexec"))
+	require.False(t, mounts[4].HasOption("noexec"))
+
+	require.Equal(t, "/mnt/my share", mounts[2].Point)
+	require.Equal(t, "cifs", mounts[2].FSType)
+	require.True(t, mounts[2].Network())
+	require.True(t, mounts[3].Network())
+	require.False(t, mounts[0].Network())
+
+	require.True(t, mounts[2].Contains("/mnt/my share/a"))
+	require.False(t, mounts[2].Contains("/mnt/my shared"))
+
+	_, err = ParseMountInfo(strings.NewReader("1 0 8:1 / / rw ext4 /dev/sda1 rw\n"))
+	require.Error(t, err)
+}
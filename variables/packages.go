@@ -0,0 +1,297 @@
+package variables
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultDpkgInfoDir is the dpkg directory of the package file lists and checksums read if PackageDB.DpkgInfoDir is
+// empty. It is relative to PackageDB.Root.
+const DefaultDpkgInfoDir = "var/lib/dpkg/info"
+
+// defaultPackageDB is the package database of the scan contexts not providing one.
+var defaultPackageDB = &PackageDB{}
+
+type (
+	// PackageDB is the index of the files installed by the package managers, which is used by the package variables
+	// such as file_package_owned. It is read from the dpkg file lists and checksums, and from an RPM database export
+	// if it is set. The databases are read once, when a package variable is first defined, and the files are indexed
+	// by their paths. It is safe for concurrent use.
+	PackageDB struct {
+		// Root is the directory the databases are read relative to, such as the mount point of an image. The files
+		// under it are looked up by their paths relative to it, unless the scan context maps them using a
+		// PathMapping. "/" is used if it is empty.
+		Root string
+		// DpkgInfoDir is the dpkg info directory relative to Root. DefaultDpkgInfoDir is used if it is empty.
+		DpkgInfoDir string
+		// RPMExport is the path of an RPM database export relative to Root, which is not read if it is empty. The
+		// export is a tab separated file of the package names, the file paths and the file digests, such as the output
+		// of the command below.
+		//
+		//	rpm -qa --qf '[%{NAME}\t%{FILENAMES}\t%{FILEDIGESTS}\n]'
+		RPMExport string
+		// VerifyDigests enables file_package_modified. It is disabled by default, since the files are read again to
+		// compute their digests.
+		VerifyDigests bool
+
+		once  sync.Once
+		files map[string]PackageFile
+		err   error
+	}
+
+	// PackageFile is a file installed by a package.
+	PackageFile struct {
+		// Package is the name of the package.
+		Package string
+		// Digest is the hex encoded MD5, SHA-1 or SHA-256 digest of the file recorded by the package manager. It is
+		// empty for the directories and the files without digests, such as the dpkg configuration files.
+		Digest string
+	}
+
+	// PackageDBProvider is an optional interface for the ScanContext implementations to set the package database of
+	// the package variables. ScanContextImpl implements it.
+	PackageDBProvider interface {
+		PackageDB() *PackageDB
+	}
+)
+
+// packageDB returns the package database of the given scan context. A package database of the host shared by the scan
+// contexts is returned if the scan context does not provide one.
+func packageDB(sCtx ScanContext) *PackageDB {
+	if p, ok := sCtx.(PackageDBProvider); ok {
+		if db := p.PackageDB(); db != nil {
+			return db
+		}
+	}
+	return defaultPackageDB
+}
+
+// Load reads the package databases if they are not read yet. The missing databases are skipped. The later calls
+// return the error of the first one.
+func (db *PackageDB) Load() error {
+	db.once.Do(func() {
+		db.files, db.err = db.load()
+	})
+	return db.err
+}
+
+// Lookup returns the package file of the given slash separated absolute path. The paths of the merged /usr
+// directories are looked up by their aliases too, such as /bin/ls for /usr/bin/ls. It returns false as second value if
+// the path is not owned by a package.
+func (db *PackageDB) Lookup(path string) (PackageFile, bool, error) {
+	if err := db.Load(); err != nil {
+		return PackageFile{}, false, err
+	}
+	if f, ok := db.files[path]; ok {
+		return f, true, nil
+	}
+	if alias := usrMergeAlias(path); alias != "" {
+		f, ok := db.files[alias]
+		return f, ok, nil
+	}
+	return PackageFile{}, false, nil
+}
+
+func (db *PackageDB) load() (map[string]PackageFile, error) {
+	files := make(map[string]PackageFile)
+	if err := db.loadDpkg(files); err != nil {
+		return nil, err
+	}
+	if err := db.loadRPMExport(files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// loadDpkg indexes the files in the <package>.list files of dpkg, and their digests in the <package>.md5sums files.
+// The files listed by many packages, such as the directories, are indexed by the first package.
+func (db *PackageDB) loadDpkg(files map[string]PackageFile) error {
+	infoDir := db.DpkgInfoDir
+	if infoDir == "" {
+		infoDir = DefaultDpkgInfoDir
+	}
+	dir := db.path(infoDir)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".list")
+		if !ok {
+			continue
+		}
+		pkg := dpkgPackage(name)
+		err := readLines(filepath.Join(dir, entry.Name()), func(line string) {
+			if _, ok := files[line]; !ok {
+				files[line] = PackageFile{Package: pkg}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".md5sums")
+		if !ok {
+			continue
+		}
+		pkg := dpkgPackage(name)
+		// The lines are the digests and the paths without the leading slashes, separated by two spaces.
+		err := readLines(filepath.Join(dir, entry.Name()), func(line string) {
+			digest, p, ok := strings.Cut(line, " ")
+			if !ok {
+				return
+			}
+			p = "/" + strings.TrimLeft(p, " ")
+			if f, ok := files[p]; !ok || f.Package == pkg {
+				files[p] = PackageFile{Package: pkg, Digest: digest}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRPMExport indexes the files in the RPM database export. The files of many packages are indexed by the first
+// package.
+func (db *PackageDB) loadRPMExport(files map[string]PackageFile) error {
+	if db.RPMExport == "" {
+		return nil
+	}
+	err := readLines(db.path(db.RPMExport), func(line string) {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || fields[1] == "" {
+			return
+		}
+		if _, ok := files[fields[1]]; ok {
+			return
+		}
+		f := PackageFile{Package: fields[0]}
+		if len(fields) > 2 {
+			f.Digest = fields[2]
+		}
+		files[fields[1]] = f
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// path returns the path of the given slash separated path relative to the root.
+func (db *PackageDB) path(name string) string {
+	root := db.Root
+	if root == "" {
+		root = "/"
+	}
+	return filepath.Join(root, filepath.FromSlash(name))
+}
+
+// filePackage returns the package file of the file of the given scan context, or nil if it is not owned by a package.
+func filePackage(sCtx ScanContext) (*PackageFile, error) {
+	p, m := originalFilePath(sCtx)
+	if p == "" {
+		return nil, nil
+	}
+	db := packageDB(sCtx)
+	if m == nil && db.Root != "" {
+		// The files of a mounted image are looked up by their paths in the image.
+		if op, ok := (&PathMapping{Root: db.Root, TargetOS: OSLinux}).Map(p); ok {
+			p = op
+		}
+	}
+	f, ok, err := db.Lookup(filepath.ToSlash(p))
+	if !ok || err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// fileDigest returns the hex encoded digest of the given file using the hash of the given digest length, or an empty
+// string if there is no such hash.
+func fileDigest(name string, length int) (string, error) {
+	var h hash.Hash
+	switch length {
+	case hex.EncodedLen(md5.Size):
+		h = md5.New()
+	case hex.EncodedLen(sha1.Size):
+		h = sha1.New()
+	case hex.EncodedLen(sha256.Size):
+		h = sha256.New()
+	default:
+		return "", nil
+	}
+
+	f, _, err := OpenNoAtime(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() // nolint errcheck
+
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// dpkgPackage returns the package name of the given base name of a dpkg info file, such as "libc6" for "libc6:amd64".
+func dpkgPackage(name string) string {
+	pkg, _, _ := strings.Cut(name, ":")
+	return pkg
+}
+
+// usrMergeAlias returns the alias of the given path on the systems with the merged /usr directories, such as /bin/ls
+// for /usr/bin/ls and /usr/lib/x for /lib/x, or an empty string if it has none.
+func usrMergeAlias(path string) string {
+	if p, ok := strings.CutPrefix(path, "/usr"); ok && isUsrMergeDir(p) {
+		return p
+	}
+	if isUsrMergeDir(path) {
+		return "/usr" + path
+	}
+	return ""
+}
+
+// isUsrMergeDir reports whether the given path is under a directory merged into /usr, such as /bin.
+func isUsrMergeDir(path string) bool {
+	for _, dir := range []string{"/bin/", "/sbin/", "/lib/", "/lib32/", "/lib64/", "/libx32/"} {
+		if strings.HasPrefix(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// readLines calls the given function with each non-empty line of the given file.
+func readLines(name string, fn func(line string)) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint errcheck
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := sc.Text(); line != "" {
+			fn(line)
+		}
+	}
+	return sc.Err()
+}
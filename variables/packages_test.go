@@ -0,0 +1,94 @@
+package variables_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/binalyze/gora/variables"
+)
+
+func TestPackageDB(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(name, content string) {
+		t.Helper()
+		name = filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+		require.NoError(t, os.WriteFile(name, []byte(content), 0o644))
+	}
+
+	lsSum := md5.Sum([]byte("ls"))
+	sshdSum := sha256.Sum256([]byte("sshd"))
+	writeFile("usr/bin/ls", "ls")
+	writeFile("usr/bin/cat", "trojan")
+	writeFile("usr/sbin/sshd", "sshd")
+	writeFile("usr/local/bin/tool", "tool")
+	writeFile("var/lib/dpkg/info/coreutils:amd64.list", "/.\n/bin\n/bin/ls\n/usr/bin/cat\n")
+	writeFile("var/lib/dpkg/info/coreutils:amd64.md5sums", hex.EncodeToString(lsSum[:])+"  bin/ls\n"+
+		"00000000000000000000000000000000  usr/bin/cat\n")
+	writeFile("var/lib/dpkg/info/base-files.list", "/.\n/etc/issue\n")
+	writeFile("rpm-files.tsv", "openssh-server\t/usr/sbin/sshd\t"+hex.EncodeToString(sshdSum[:])+"\n"+
+		"openssh-server\t/usr/share/doc/openssh\t\n")
+
+	db := &PackageDB{Root: root, RPMExport: "rpm-files.tsv"}
+	f, ok, err := db.Lookup("/usr/bin/ls")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "coreutils", f.Package)
+	require.Equal(t, hex.EncodeToString(lsSum[:]), f.Digest)
+
+	f, ok, err = db.Lookup("/etc/issue")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, PackageFile{Package: "base-files"}, f)
+
+	_, ok, err = db.Lookup("/usr/local/bin/tool")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	var sctx ScanContextImpl
+	sctx.SetPackageDB(db)
+	value := func(v VariableType, name string) interface{} {
+		t.Helper()
+		path := filepath.Join(root, filepath.FromSlash(name))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		sctx.SetFilePath(path)
+		sctx.SetFileInfo(info)
+		value, err := Valuers[v].Value(&sctx)
+		require.NoError(t, err)
+		return value
+	}
+
+	require.Equal(t, true, value(VarFilePackageOwned, "usr/bin/ls"))
+	require.Equal(t, "coreutils", value(VarFilePackageName, "usr/bin/ls"))
+	// The digests are verified only if it is enabled.
+	require.Nil(t, value(VarFilePackageModified, "usr/bin/cat"))
+	db.VerifyDigests = true
+	require.Equal(t, false, value(VarFilePackageModified, "usr/bin/ls"))
+	require.Equal(t, true, value(VarFilePackageModified, "usr/bin/cat"))
+	require.Equal(t, "openssh-server", value(VarFilePackageName, "usr/sbin/sshd"))
+	require.Equal(t, false, value(VarFilePackageModified, "usr/sbin/sshd"))
+	require.Equal(t, false, value(VarFilePackageOwned, "usr/local/bin/tool"))
+	require.Nil(t, value(VarFilePackageName, "usr/local/bin/tool"))
+	require.Nil(t, value(VarFilePackageModified, "usr/local/bin/tool"))
+
+	// The files are read from their real paths, such as /proc/<pid>/fd/3 of a deleted file.
+	sctx.SetRealPath(filepath.Join(root, "usr", "bin", "cat"))
+	require.Equal(t, true, value(VarFilePackageModified, "usr/bin/ls"))
+	sctx.SetRealPath("")
+	require.Nil(t, value(VarFilePackageModified, "usr/bin/ls"))
+	sctx.Reset()
+	sctx.SetPackageDB(db)
+
+	// The path mapping of the scan context is used to look up the files if it is set, and the paths of the merged /usr
+	// directories are looked up by their aliases.
+	sctx.SetPathMapping(&PathMapping{Root: filepath.Join(root, "usr"), TargetOS: OSLinux})
+	require.Equal(t, "openssh-server", value(VarFilePackageName, "usr/sbin/sshd"))
+	require.Equal(t, false, value(VarFilePackageOwned, "usr/local/bin/tool"))
+}
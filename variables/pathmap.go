@@ -0,0 +1,123 @@
+package variables
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// defaultWindowsDrive is the drive of the original paths mapped to Windows.
+const defaultWindowsDrive = "C:"
+
+type (
+	// PathMapping maps the real paths of the files in a mounted evidence, such as a disk image mounted at
+	// /mnt/evidence, to their paths on the original system, such as /etc/cron.d/x for /mnt/evidence/etc/cron.d/x. The
+	// path based variables, file_path, file_name, file_extension and file_hidden, are defined using the original
+	// paths, while the real paths are still used to read the files and reported in the results.
+	PathMapping struct {
+		// Root is the real path of the root of the original system.
+		Root string
+		// TargetOS is the operating system of the original system, which determines the format of the original
+		// paths. The host operating system is used if it is zero.
+		TargetOS OSType
+		// Drive is the drive of the original paths if the target is Windows. It is "C:" if it is empty.
+		Drive string
+	}
+
+	// PathMapper is an optional interface for the ScanContext implementations to define the path based variables
+	// using the original paths of the files. ScanContextImpl implements it.
+	PathMapper interface {
+		PathMapping() *PathMapping
+	}
+
+	// RealPathProvider is an optional interface for the ScanContext implementations to set the path the file is read
+	// from on the host when it differs from the file path, such as /proc/<pid>/fd/3 for a deleted file, or a path under
+	// /proc/<pid>/root for a file of a container. The file attributes, such as file_immutable and file_fs_type, are
+	// read from it. An empty path means the file cannot be read from the host, and the attribute variables are
+	// defined with their default values. ScanContextImpl implements it.
+	RealPathProvider interface {
+		RealPath() string
+	}
+)
+
+// Map returns the original path of the given real path. It returns false as second value if the path is not under
+// the root.
+func (m *PathMapping) Map(realPath string) (string, bool) {
+	if m == nil || m.Root == "" || realPath == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(m.Root, realPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		rel = ""
+	}
+	if !m.windows() {
+		return "/" + rel, true
+	}
+	drive := m.Drive
+	if drive == "" {
+		drive = defaultWindowsDrive
+	}
+	return drive + `\` + strings.ReplaceAll(rel, "/", `\`), true
+}
+
+// windows reports whether the original paths are Windows paths.
+func (m *PathMapping) windows() bool {
+	if m.TargetOS == 0 {
+		return runtime.GOOS == "windows"
+	}
+	return m.TargetOS == OSWindows
+}
+
+// base returns the last element of the given original path.
+func (m *PathMapping) base(p string) string {
+	seps := "/"
+	if m.windows() {
+		seps = `\/`
+	}
+	p = strings.TrimRight(p, seps)
+	if i := strings.LastIndexAny(p, seps); i >= 0 {
+		p = p[i+1:]
+	}
+	if strings.HasSuffix(p, ":") {
+		return ""
+	}
+	return p
+}
+
+// originalFilePath returns the file path of the given scan context on the original system, and its path mapping if
+// it is mapped.
+func originalFilePath(sCtx ScanContext) (string, *PathMapping) {
+	p := sCtx.FilePath()
+	if mapper, ok := sCtx.(PathMapper); ok && p != "" {
+		m := mapper.PathMapping()
+		if op, ok := m.Map(p); ok {
+			return op, m
+		}
+	}
+	if p == "" {
+		return "", nil
+	}
+	return filepath.Clean(p), nil
+}
+
+// realFilePath returns the path the file of the given scan context is read from on the host, or an empty string if it
+// cannot be read from the host. It is the file path unless the scan context implements RealPathProvider.
+func realFilePath(sCtx ScanContext) string {
+	if p, ok := sCtx.(RealPathProvider); ok {
+		return p.RealPath()
+	}
+	return sCtx.FilePath()
+}
+
+// pathBase returns the last element of the given path using the given path mapping if it is not nil.
+func pathBase(p string, m *PathMapping) string {
+	if m != nil {
+		return m.base(p)
+	}
+	return filepath.Base(p)
+}
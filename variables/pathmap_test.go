@@ -0,0 +1,60 @@
+package variables_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/binalyze/gora/variables"
+)
+
+func TestPathMappingMap(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "mnt", "evidence")
+	linux := PathMapping{Root: root, TargetOS: OSLinux}
+	windows := PathMapping{Root: root, TargetOS: OSWindows}
+
+	tests := []struct {
+		name    string
+		mapping PathMapping
+		path    string
+		want    string
+		ok      bool
+	}{
+		{"linux", linux, filepath.Join(root, "etc", "cron.d", "x"), "/etc/cron.d/x", true},
+		{"linux root", linux, root, "/", true},
+		{"windows", windows, filepath.Join(root, "Windows", "a.exe"), `C:\Windows\a.exe`, true},
+		{"drive", PathMapping{Root: root, TargetOS: OSWindows, Drive: "D:"}, filepath.Join(root, "a.exe"), `D:\a.exe`, true},
+		{"outside", linux, filepath.Join(root+"2", "x"), "", false},
+		{"no root", PathMapping{TargetOS: OSLinux}, filepath.Join(root, "x"), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.mapping.Map(tt.path)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPathMappingVariables(t *testing.T) {
+	root := t.TempDir()
+
+	var sctx ScanContextImpl
+	sctx.SetFilePath(filepath.Join(root, "Users", "x", ".hidden.PS1"))
+	sctx.SetPathMapping(&PathMapping{Root: root, TargetOS: OSWindows})
+
+	want := map[VariableType]interface{}{
+		VarFilePath:      `C:\Users\x\.hidden.PS1`,
+		VarFileName:      ".hidden.PS1",
+		VarFileExtension: "PS1",
+	}
+	for v, w := range want {
+		value, err := Valuers[v].Value(&sctx)
+		require.NoError(t, err)
+		require.Equal(t, w, value, v.String())
+	}
+
+	sctx.Reset()
+	require.Nil(t, sctx.PathMapping())
+}
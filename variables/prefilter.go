@@ -0,0 +1,322 @@
+package variables
+
+import (
+	"strings"
+
+	"github.com/VirusTotal/gyp/ast"
+)
+
+// Prefilter evaluates the parts of yara rule conditions which only depend on external variables and the file size,
+// without reading the scanned file. It is used to skip scanning the files which cannot be matched by any rule.
+//
+// Conditions are evaluated using three-valued logic. Any expression which needs the file content, such as strings,
+// modules or functions, is unknown, and a rule can only be excluded if its condition is false regardless of the
+// unknown parts. Private rules are never reported by the scanner, therefore they are only used to resolve the rule
+// references.
+type Prefilter struct {
+	namespaces []*prefilterNamespace
+}
+
+type prefilterNamespace struct {
+	name  string
+	rules []*ast.Rule
+}
+
+// NewPrefilter creates a new empty Prefilter. An empty Prefilter excludes all the files since there is no rule to
+// match.
+func NewPrefilter() *Prefilter {
+	return &Prefilter{}
+}
+
+// Add adds the rules in the given rule set to the given namespace. Rule sets must be added in the order they are
+// compiled.
+func (p *Prefilter) Add(rs *ast.RuleSet, namespace string) {
+	var ns *prefilterNamespace
+	for _, n := range p.namespaces {
+		if n.name == namespace {
+			ns = n
+			break
+		}
+	}
+	if ns == nil {
+		ns = &prefilterNamespace{name: namespace}
+		p.namespaces = append(p.namespaces, ns)
+	}
+	for _, rule := range rs.Rules {
+		if rule != nil && rule.Condition != nil {
+			ns.rules = append(ns.rules, rule)
+		}
+	}
+}
+
+// CanMatch reports whether any rule could match the scan target of the given ScanContext. If it returns false, none of
+// the non-private rules can match the target, and scanning it can be skipped.
+//
+// Variable values are calculated using the registered Valuers. A Valuer error makes the variable unknown. The file
+// size is only known if the ScanContext is in the file system and has a FileInfo.
+func (p *Prefilter) CanMatch(sCtx ScanContext) bool {
+	return p.CanMatchValues(new(Variables).NewValues(sCtx))
+}
+
+// CanMatchValues reports whether any rule could match the scan target like CanMatch, using the given values of the
+// scan target. The values calculated are reused to define the variables with Variables.DefineScannerValues.
+func (p *Prefilter) CanMatchValues(values *Values) bool {
+	e := &prefilterEval{sCtx: values.ScanContext(), vars: values}
+	for _, ns := range p.namespaces {
+		if e.canMatchNamespace(ns) {
+			return true
+		}
+	}
+	return false
+}
+
+type prefilterEval struct {
+	sCtx   ScanContext
+	vars   *Values
+	values [typeEnd]interface{}
+	known  [typeEnd]bool
+	done   [typeEnd]bool
+	// falseRules holds the rules of the current namespace which are known to be false.
+	falseRules map[string]struct{}
+}
+
+func (e *prefilterEval) canMatchNamespace(ns *prefilterNamespace) bool {
+	e.falseRules = make(map[string]struct{})
+
+	canMatch := false
+	for _, rule := range ns.rules {
+		v, ok := e.eval(rule.Condition, 1)
+		if b, isBool := toBool(v); ok && isBool && !b {
+			if rule.Global {
+				// All rules in the namespace are false if a global rule is false.
+				return false
+			}
+			e.falseRules[rule.Identifier] = struct{}{}
+			continue
+		}
+		if !rule.Private {
+			canMatch = true
+		}
+	}
+	return canMatch
+}
+
+// eval evaluates the given expression. It returns false as second value if the value is unknown.
+func (e *prefilterEval) eval(expr ast.Expression, depth int) (interface{}, bool) {
+	if expr == nil || depth > depthLimit {
+		return nil, false
+	}
+
+	switch x := expr.(type) {
+	case ast.Keyword:
+		switch x {
+		case ast.KeywordTrue:
+			return true, true
+		case ast.KeywordFalse:
+			return false, true
+		case ast.KeywordFilesize:
+			return e.fileSize()
+		}
+	case *ast.LiteralInteger:
+		return x.Value, true
+	case *ast.LiteralString:
+		return x.Value, true
+	case *ast.Identifier:
+		return e.identifier(x.Identifier)
+	case *ast.Group:
+		return e.eval(x.Expression, depth+1)
+	case *ast.Not:
+		v, ok := e.eval(x.Expression, depth+1)
+		if b, isBool := toBool(v); ok && isBool {
+			return !b, true
+		}
+	case *ast.Minus:
+		v, ok := e.eval(x.Expression, depth+1)
+		if i, isInt := v.(int64); ok && isInt {
+			return -i, true
+		}
+	case *ast.Operation:
+		return e.operation(x, depth)
+	}
+	return nil, false
+}
+
+func (e *prefilterEval) operation(op *ast.Operation, depth int) (interface{}, bool) {
+	switch op.Operator {
+	case ast.OpAnd, ast.OpOr:
+		// short is the value which determines the result alone, false for "and" and true for "or".
+		short := op.Operator == ast.OpOr
+		allKnown := true
+		for _, operand := range op.Operands {
+			v, ok := e.eval(operand, depth+1)
+			b, isBool := toBool(v)
+			if !ok || !isBool {
+				allKnown = false
+				continue
+			}
+			if b == short {
+				return short, true
+			}
+		}
+		if allKnown {
+			return !short, true
+		}
+		return nil, false
+	}
+
+	if len(op.Operands) < 2 {
+		return nil, false
+	}
+	values := make([]interface{}, 0, len(op.Operands))
+	for _, operand := range op.Operands {
+		v, ok := e.eval(operand, depth+1)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, v)
+	}
+
+	switch a := values[0].(type) {
+	case int64:
+		return intOperation(op.Operator, a, values[1:])
+	case string:
+		b, ok := values[1].(string)
+		if !ok || len(values) != 2 {
+			return nil, false
+		}
+		return stringOperation(op.Operator, a, b)
+	case bool:
+		b, ok := values[1].(bool)
+		if !ok || len(values) != 2 {
+			return nil, false
+		}
+		switch op.Operator {
+		case ast.OpEqual:
+			return a == b, true
+		case ast.OpNotEqual:
+			return a != b, true
+		}
+	}
+	return nil, false
+}
+
+func intOperation(op ast.OperatorType, acc int64, values []interface{}) (interface{}, bool) {
+	for _, v := range values {
+		b, ok := v.(int64)
+		if !ok {
+			return nil, false
+		}
+		switch op {
+		case ast.OpAdd:
+			acc += b
+		case ast.OpSub:
+			acc -= b
+		case ast.OpMul:
+			acc *= b
+		default:
+			if len(values) != 1 {
+				return nil, false
+			}
+			return intCompare(op, acc, b)
+		}
+	}
+	return acc, true
+}
+
+func intCompare(op ast.OperatorType, a, b int64) (interface{}, bool) {
+	switch op {
+	case ast.OpEqual:
+		return a == b, true
+	case ast.OpNotEqual:
+		return a != b, true
+	case ast.OpLessThan:
+		return a < b, true
+	case ast.OpLessOrEqual:
+		return a <= b, true
+	case ast.OpGreaterThan:
+		return a > b, true
+	case ast.OpGreaterOrEqual:
+		return a >= b, true
+	}
+	return nil, false
+}
+
+func stringOperation(op ast.OperatorType, a, b string) (interface{}, bool) {
+	switch op {
+	case ast.OpEqual:
+		return a == b, true
+	case ast.OpNotEqual:
+		return a != b, true
+	case ast.OpIEquals:
+		return strings.EqualFold(a, b), true
+	case ast.OpContains:
+		return strings.Contains(a, b), true
+	case ast.OpIContains:
+		return strings.Contains(strings.ToLower(a), strings.ToLower(b)), true
+	case ast.OpStartsWith:
+		return strings.HasPrefix(a, b), true
+	case ast.OpIStartsWith:
+		return strings.HasPrefix(strings.ToLower(a), strings.ToLower(b)), true
+	case ast.OpEndsWith:
+		return strings.HasSuffix(a, b), true
+	case ast.OpIEndsWith:
+		return strings.HasSuffix(strings.ToLower(a), strings.ToLower(b)), true
+	}
+	return nil, false
+}
+
+func (e *prefilterEval) identifier(name string) (interface{}, bool) {
+	if _, ok := e.falseRules[name]; ok {
+		return false, true
+	}
+	vid, ok := Lookup(name)
+	if !ok {
+		return nil, false
+	}
+	if !e.done[vid] {
+		e.done[vid] = true
+		e.values[vid], e.known[vid] = e.value(vid)
+	}
+	return e.values[vid], e.known[vid]
+}
+
+// value calculates the variable value as it would be defined to the scanner.
+func (e *prefilterEval) value(vid VariableType) (interface{}, bool) {
+	value, err := e.vars.Value(vid)
+	if err != nil {
+		return nil, false
+	}
+	if value == nil {
+		value = defaultValue(vid)
+	}
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64, bool, string:
+		return v, true
+	}
+	return nil, false
+}
+
+func (e *prefilterEval) fileSize() (interface{}, bool) {
+	if !e.sCtx.InFileSystem() {
+		return nil, false
+	}
+	info := e.sCtx.FileInfo()
+	if info == nil {
+		return nil, false
+	}
+	return info.Size(), true
+}
+
+func toBool(v interface{}) (bool, bool) {
+	switch b := v.(type) {
+	case bool:
+		return b, true
+	case int64:
+		return b != 0, true
+	}
+	return false, false
+}
@@ -0,0 +1,154 @@
+package variables_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VirusTotal/gyp/parser"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora/variables"
+)
+
+func newPrefilter(t *testing.T, rules ...string) *variables.Prefilter {
+	t.Helper()
+	p := variables.NewPrefilter()
+	for _, rule := range rules {
+		rs, err := parser.Parse(strings.NewReader(rule))
+		require.NoError(t, err)
+		p.Add(rs, "")
+	}
+	return p
+}
+
+func fileScanContext(t *testing.T, name string, size int) *variables.ScanContextImpl {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0o600))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	sctx := new(variables.ScanContextImpl)
+	sctx.SetInFileSystem(true)
+	sctx.SetFilePath(path)
+	sctx.SetFileInfo(info)
+	return sctx
+}
+
+func TestPrefilter_CanMatch(t *testing.T) {
+	p := newPrefilter(t, `
+	rule php {
+		strings:
+			$a = "eval("
+		condition:
+			file_extension == "php" and filesize < 1KB and $a
+	}
+	rule js { condition: file_extension iequals "JS" and (filesize > 10 or pe.is_dll()) }
+	`)
+
+	testCases := []struct {
+		name   string
+		size   int
+		expect bool
+	}{
+		{name: "a.php", size: 10, expect: true},
+		{name: "a.php", size: 2048, expect: false},
+		{name: "a.txt", size: 10, expect: false},
+		{name: "a.js", size: 1, expect: true},
+		{name: "a.jsx", size: 100, expect: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			require.Equal(t, tC.expect, p.CanMatch(fileScanContext(t, tC.name, tC.size)))
+		})
+	}
+}
+
+func TestPrefilter_Unknown(t *testing.T) {
+	p := newPrefilter(t, `rule x { condition: not (file_name == "a" and uint16(0) == 0x5A4D) }`)
+	require.True(t, p.CanMatch(fileScanContext(t, "a", 1)))
+
+	// File size is unknown outside of the file system.
+	p = newPrefilter(t, `rule x { condition: filesize > 100 }`)
+	sctx := fileScanContext(t, "a", 1)
+	require.False(t, p.CanMatch(sctx))
+	sctx.SetInFileSystem(false)
+	require.True(t, p.CanMatch(sctx))
+}
+
+func TestPrefilter_RulesAndNamespaces(t *testing.T) {
+	// Private rules are not reported, and references to false rules are false.
+	p := newPrefilter(t, `
+	private rule is_php { condition: file_extension == "php" }
+	rule uses_private { condition: is_php and filesize > 0 }
+	`)
+	require.False(t, p.CanMatch(fileScanContext(t, "a.txt", 1)))
+	require.True(t, p.CanMatch(fileScanContext(t, "a.php", 1)))
+
+	// A false global rule disables its namespace only.
+	p = newPrefilter(t, `
+	global rule only_exe { condition: file_extension == "exe" }
+	rule all_files { condition: true }
+	`)
+	require.False(t, p.CanMatch(fileScanContext(t, "a.txt", 1)))
+
+	rs, err := parser.Parse(strings.NewReader(`rule other { condition: true }`))
+	require.NoError(t, err)
+	p.Add(rs, "other")
+	require.True(t, p.CanMatch(fileScanContext(t, "a.txt", 1)))
+
+	require.False(t, variables.NewPrefilter().CanMatch(fileScanContext(t, "a.txt", 1)))
+}
+
+// definedValues is a VariableDefiner recording the defined values.
+type definedValues map[string]interface{}
+
+func (d definedValues) DefineVariable(name string, value interface{}) error {
+	d[name] = value
+	return nil
+}
+
+func TestPrefilter_CanMatchValues(t *testing.T) {
+	orig := variables.Valuers
+	t.Cleanup(func() {
+		variables.Valuers = orig
+	})
+
+	calls := 0
+	variables.Valuers[variables.VarFileName] = variables.ValueFunc(func(_ variables.ScanContext) (interface{}, error) {
+		calls++
+		return "a.exe", nil
+	})
+
+	p := newPrefilter(t, `rule x { condition: file_name == "a.exe" }`)
+	var vr variables.Variables
+	vr.InitVariables([]variables.VariableType{variables.VarFileName})
+	sctx := fileScanContext(t, "a.exe", 1)
+	sctx.SetHandleValueError(variables.IgnoreValueErrors)
+
+	// the values calculated by the prefilter are reused to define the variables.
+	values := vr.NewValues(sctx)
+	require.True(t, p.CanMatchValues(values))
+	defined := definedValues{}
+	require.NoError(t, vr.DefineScannerValues(values, defined))
+	require.Equal(t, "a.exe", defined["file_name"])
+	require.Equal(t, 1, calls)
+
+	// the valuer timeout applies to the prefilter as well, and the variable is unknown.
+	release := make(chan struct{})
+	t.Cleanup(func() {
+		close(release)
+	})
+	variables.Valuers[variables.VarFileName] = variables.ValueFunc(func(_ variables.ScanContext) (interface{}, error) {
+		<-release
+		return "a.exe", nil
+	})
+	vr.SetValuerTimeout(10 * time.Millisecond)
+	values = vr.NewValues(sctx)
+	require.True(t, p.CanMatchValues(values))
+	require.NoError(t, vr.DefineScannerValues(values, defined))
+	require.Equal(t, "", defined["file_name"])
+}
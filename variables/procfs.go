@@ -0,0 +1,96 @@
+package variables
+
+import (
+	"bytes"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultProcfsRoot is the mount point of procfs used if Procfs.Root is empty.
+	DefaultProcfsRoot = "/proc"
+	// DefaultNetMaxAge is the duration the sockets are cached for if Procfs.NetMaxAge is zero.
+	DefaultNetMaxAge = 10 * time.Second
+)
+
+// defaultProcfs is the procfs settings of the scan contexts not providing them.
+var defaultProcfs = &Procfs{}
+
+type (
+	// Procfs holds the settings of the process variables read from procfs on Linux, such as process_environment and
+	// process_loaded_libraries. It also caches the socket tables and the sockets of the processes read for the
+	// process network variables, so share one Procfs between the scans of a sweep. It must not be copied after first
+	// use.
+	Procfs struct {
+		// Root is the mount point of procfs. DefaultProcfsRoot is used if it is empty. It can be set to a directory of
+		// fixtures in the tests, or to the procfs of a mounted evidence.
+		Root string
+		// Environment enables process_environment and process_ld_preload. It is disabled by default, since the
+		// environment of the processes may hold secrets.
+		Environment bool
+		// EnvironmentKeys are the path.Match patterns of the keys of the environment variables included in
+		// process_environment, such as "LD_*". All the keys are included if it is empty.
+		EnvironmentKeys []string
+		// NetMaxAge is the duration the socket tables and the sockets of the processes are cached for.
+		// DefaultNetMaxAge is used if it is zero.
+		NetMaxAge time.Duration
+
+		netMu     sync.Mutex
+		netReadAt time.Time
+		net       map[string]*netTables
+		procNet   map[int]*processSockets
+	}
+
+	// ProcfsProvider is an optional interface for the ScanContext implementations to set the procfs settings of the
+	// process variables. ScanContextImpl implements it.
+	ProcfsProvider interface {
+		Procfs() *Procfs
+	}
+)
+
+// procfs returns the procfs settings of the given scan context. The zero settings shared by the scan contexts are
+// returned if the scan context does not provide them.
+func procfs(sCtx ScanContext) *Procfs {
+	if p, ok := sCtx.(ProcfsProvider); ok {
+		if fs := p.Procfs(); fs != nil {
+			return fs
+		}
+	}
+	return defaultProcfs
+}
+
+// path returns the path of the given file of the given process in procfs.
+func (p *Procfs) path(pid int, name string) string {
+	root := p.Root
+	if root == "" {
+		root = DefaultProcfsRoot
+	}
+	return filepath.Join(root, strconv.Itoa(pid), name)
+}
+
+// includesKey reports whether the given environment variable key is included in process_environment.
+func (p *Procfs) includesKey(key string) bool {
+	if len(p.EnvironmentKeys) == 0 {
+		return true
+	}
+	for _, pattern := range p.EnvironmentKeys {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// environ splits the given content of /proc/<pid>/environ into the environment variables.
+func environ(data []byte) []string {
+	env := []string{}
+	for _, kv := range bytes.Split(data, []byte{0}) {
+		if len(kv) > 0 {
+			env = append(env, string(kv))
+		}
+	}
+	return env
+}
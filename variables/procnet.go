@@ -0,0 +1,346 @@
+package variables
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/cpu"
+)
+
+// The socket states in the socket tables of procfs. The unconnected UDP sockets are in the close state.
+const (
+	socketClose  = 0x07
+	socketListen = 0x0a
+)
+
+// unixAcceptCon is the flag of the listening sockets in the unix socket table of procfs.
+const unixAcceptCon = 0x10000
+
+var (
+	// inetTables are the socket tables of procfs listing the addresses of the sockets.
+	inetTables = []string{"tcp", "tcp6", "udp", "udp6"}
+	// rawTables are the socket tables of procfs listing the raw sockets, and the column of their inodes.
+	rawTables = map[string]int{"raw": 9, "raw6": 9, "packet": 8}
+)
+
+type (
+	// socket is an entry of the TCP or UDP socket tables of procfs.
+	socket struct {
+		proto  string
+		local  netip.AddrPort
+		remote netip.AddrPort
+		state  uint64
+	}
+
+	// netTables holds the sockets of a network namespace by their inodes.
+	netTables struct {
+		sockets map[uint64]socket
+		raw     map[uint64]struct{}
+		// unix are the paths of the listening unix sockets bound to a path, or to an abstract name prefixed with "@".
+		unix map[uint64]string
+	}
+
+	// processSockets is the network activity of a process.
+	processSockets struct {
+		count     int
+		listening []string
+		remote    []string
+		raw       bool
+	}
+)
+
+// ResetNetCache clears the socket tables and the sockets of the processes cached for the process network variables,
+// such as process_listening_ports, so they are read again. ScanProcesses and ScanDeletedFiles reset them at the start
+// of each sweep.
+func (p *Procfs) ResetNetCache() {
+	p.netMu.Lock()
+	defer p.netMu.Unlock()
+	p.net, p.procNet = nil, nil
+}
+
+// expireNetCache clears the cached sockets if they are older than NetMaxAge. p.netMu must be held.
+func (p *Procfs) expireNetCache() {
+	maxAge := p.NetMaxAge
+	if maxAge == 0 {
+		maxAge = DefaultNetMaxAge
+	}
+	if time.Since(p.netReadAt) >= maxAge {
+		p.net, p.procNet = nil, nil
+		p.netReadAt = time.Now()
+	}
+}
+
+// sockets returns the network activity of the given process, which is read once and cached. It returns nil if there
+// is no such process.
+func (p *Procfs) sockets(pid int) (*processSockets, error) {
+	p.netMu.Lock()
+	p.expireNetCache()
+	ps, ok := p.procNet[pid]
+	p.netMu.Unlock()
+	if ok {
+		return ps, nil
+	}
+
+	ps, err := p.readSockets(pid)
+	if ps == nil || err != nil {
+		return nil, err
+	}
+	p.netMu.Lock()
+	defer p.netMu.Unlock()
+	if p.procNet == nil {
+		p.procNet = make(map[int]*processSockets)
+	}
+	p.procNet[pid] = ps
+	return ps, nil
+}
+
+// readSockets reads the network activity of the given process. The sockets open by the process are read from
+// /proc/<pid>/fd and looked up in the socket tables of its network namespace. It returns nil if there is no such
+// process.
+func (p *Procfs) readSockets(pid int) (*processSockets, error) {
+	dir := p.path(pid, "fd")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inodes := make(map[uint64]struct{})
+	for _, entry := range entries {
+		// The descriptors closed in the meantime are skipped.
+		link, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if ino, ok := socketInode(link); ok {
+			inodes[ino] = struct{}{}
+		}
+	}
+	ps := &processSockets{count: len(inodes)}
+	if len(inodes) == 0 {
+		return ps, nil
+	}
+
+	t, err := p.netTables(pid)
+	if err != nil {
+		return nil, err
+	}
+	listening := make(map[string]struct{})
+	remote := make(map[string]struct{})
+	for ino := range inodes {
+		if _, ok := t.raw[ino]; ok {
+			ps.raw = true
+		}
+		if path, ok := t.unix[ino]; ok {
+			listening["unix/"+path] = struct{}{}
+		}
+		s, ok := t.sockets[ino]
+		if !ok {
+			continue
+		}
+		switch {
+		case s.listening():
+			listening[fmt.Sprintf("%s/%d", s.proto, s.local.Port())] = struct{}{}
+		case s.remote.Port() != 0:
+			remote[s.remote.String()] = struct{}{}
+		}
+	}
+	ps.listening = sortedKeys(listening)
+	ps.remote = sortedKeys(remote)
+	return ps, nil
+}
+
+// netTables returns the socket tables of the network namespace of the given process. The tables are cached by the
+// network namespace, or by the process if its namespace is not known.
+func (p *Procfs) netTables(pid int) (*netTables, error) {
+	dir := p.path(pid, "net")
+	key, err := os.Readlink(p.path(pid, "ns/net"))
+	if err != nil {
+		key = dir
+	}
+
+	p.netMu.Lock()
+	defer p.netMu.Unlock()
+	p.expireNetCache()
+	if t, ok := p.net[key]; ok {
+		return t, nil
+	}
+	t, err := readNetTables(dir)
+	if err != nil {
+		return nil, err
+	}
+	if p.net == nil {
+		p.net = make(map[string]*netTables)
+	}
+	p.net[key] = t
+	return t, nil
+}
+
+// listening reports whether the socket is a listening TCP socket, or an unconnected UDP socket bound to a port.
+func (s *socket) listening() bool {
+	if s.proto == "tcp" {
+		return s.state == socketListen
+	}
+	return s.state == socketClose && s.local.Port() != 0 && s.remote.Port() == 0
+}
+
+// readNetTables reads the socket tables in the given net directory of procfs. The missing tables, such as tcp6 if
+// IPv6 is disabled, are skipped.
+func readNetTables(dir string) (*netTables, error) {
+	t := &netTables{
+		sockets: make(map[uint64]socket),
+		raw:     make(map[uint64]struct{}),
+		unix:    make(map[uint64]string),
+	}
+	for _, name := range inetTables {
+		proto := strings.TrimSuffix(name, "6")
+		err := readNetTable(filepath.Join(dir, name), func(fields []string) error {
+			if len(fields) < 10 {
+				return fmt.Errorf("invalid socket entry %q", strings.Join(fields, " "))
+			}
+			s := socket{proto: proto}
+			var errs [4]error
+			s.local, errs[0] = parseSocketAddr(fields[1])
+			s.remote, errs[1] = parseSocketAddr(fields[2])
+			s.state, errs[2] = strconv.ParseUint(fields[3], 16, 8)
+			var ino uint64
+			ino, errs[3] = strconv.ParseUint(fields[9], 10, 64)
+			if err := errors.Join(errs[:]...); err != nil {
+				return err
+			}
+			t.sockets[ino] = s
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for name, column := range rawTables {
+		column := column
+		err := readNetTable(filepath.Join(dir, name), func(fields []string) error {
+			if len(fields) <= column {
+				return fmt.Errorf("invalid socket entry %q", strings.Join(fields, " "))
+			}
+			ino, err := strconv.ParseUint(fields[column], 10, 64)
+			if err != nil {
+				return err
+			}
+			t.raw[ino] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The columns of the unix sockets are Num, RefCount, Protocol, Flags, Type, St, Inode and the optional Path.
+	err := readNetTable(filepath.Join(dir, "unix"), func(fields []string) error {
+		if len(fields) < 7 {
+			return fmt.Errorf("invalid socket entry %q", strings.Join(fields, " "))
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil {
+			return err
+		}
+		ino, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			return err
+		}
+		if flags&unixAcceptCon != 0 && len(fields) > 7 {
+			t.unix[ino] = strings.Join(fields[7:], " ")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// readNetTable calls the given function with the fields of each entry of the given socket table, skipping its header.
+func readNetTable(name string, fn func(fields []string) error) error {
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint errcheck
+
+	r := bufio.NewReader(f)
+	if _, err = r.ReadString('\n'); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		return err
+	}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if err := fn(fields); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return sc.Err()
+}
+
+// parseSocketAddr parses the given address of a socket table, such as "0100007F:1F90" for 127.0.0.1:8080. The address
+// is printed as 32-bit words in the host byte order, and the port in hexadecimal.
+func parseSocketAddr(s string) (netip.AddrPort, error) {
+	addrHex, portHex, ok := strings.Cut(s, ":")
+	if !ok || (len(addrHex) != 8 && len(addrHex) != 32) {
+		return netip.AddrPort{}, fmt.Errorf("invalid socket address %q", s)
+	}
+	b, err := hex.DecodeString(addrHex)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("invalid socket address %q: %w", s, err)
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("invalid socket port %q: %w", s, err)
+	}
+	if !cpu.IsBigEndian {
+		for i := 0; i < len(b); i += 4 {
+			binary.BigEndian.PutUint32(b[i:], binary.LittleEndian.Uint32(b[i:]))
+		}
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return netip.AddrPortFrom(addr.Unmap(), uint16(port)), nil
+}
+
+// socketInode returns the inode of the socket of the given target of a /proc/<pid>/fd link, such as "socket:[1234]".
+func socketInode(link string) (uint64, bool) {
+	s, ok := strings.CutPrefix(link, "socket:[")
+	if !ok || !strings.HasSuffix(s, "]") {
+		return 0, false
+	}
+	ino, err := strconv.ParseUint(strings.TrimSuffix(s, "]"), 10, 64)
+	return ino, err == nil
+}
+
+// sortedKeys returns the sorted keys of the given set.
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,393 @@
+package variables
+
+import (
+	"io"
+	"strings"
+
+	"github.com/VirusTotal/gyp/ast"
+	"github.com/VirusTotal/gyp/parser"
+)
+
+// hostConstVars holds the variables whose values never change for the running host. Their values can be substituted
+// into rule conditions at compile time.
+var hostConstVars = []VariableType{
+	VarOs,
+	VarOsLinux,
+	VarOsWindows,
+	VarOsDarwin,
+	VarOsAIX,
+}
+
+// HostConstants returns the values of the variables which are known to be constant on the running host. Values are
+// calculated using the registered Valuers.
+func HostConstants() map[VariableType]interface{} {
+	consts := make(map[VariableType]interface{}, len(hostConstVars))
+	for _, vid := range hostConstVars {
+		value, err := Valuers[vid].Value(nil)
+		if err != nil || value == nil {
+			continue
+		}
+		consts[vid] = value
+	}
+	return consts
+}
+
+// Pruner substitutes constant variable values into the conditions of yara rules, folds the conditions and drops the
+// rules which can never match. A Pruner must be used for a single namespace, and rule sets must be pruned in the
+// order they are compiled since references to dropped rules are resolved using the previous calls. If a namespace is
+// compiled from many rule sets, all of them must be passed to Protect before the first one is pruned, since the later
+// rule sets may refer to the rules of the earlier ones.
+//
+// Pruning never changes the semantics of the remaining rules. A condition is only replaced with its folded version
+// if the folded version references the same strings, and rules referenced by rule sets, such as "any of (a*)", or
+// global rules are never dropped.
+type Pruner struct {
+	consts    map[string]ast.Expression
+	dropped   map[string]struct{}
+	protected []string
+}
+
+// NewPruner creates a new Pruner which substitutes the given variable values. Use HostConstants to get the values of
+// the running host.
+func NewPruner(consts map[VariableType]interface{}) *Pruner {
+	p := &Pruner{
+		consts:  make(map[string]ast.Expression, len(consts)),
+		dropped: make(map[string]struct{}),
+	}
+	for vid, value := range consts {
+		if lit := literalOf(value); lit != nil {
+			p.consts[vid.String()] = lit
+		}
+	}
+	return p
+}
+
+// PruneReader parses the given io.Reader which must provide valid yara rules and prunes them. It returns the pruned
+// rule set and the identifiers of the dropped rules. Rule sets with includes are returned without any change since
+// the included rules cannot be seen by the Pruner.
+func (p *Pruner) PruneReader(rd io.Reader) (*ast.RuleSet, []string, error) {
+	rs, err := parser.Parse(rd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rs.Includes) > 0 {
+		p.Protect(rs)
+		return rs, nil, nil
+	}
+	return rs, p.Prune(rs), nil
+}
+
+// Protect marks the rules referenced by the rule sets in the conditions of the given rule set, such as
+// "any of (a, b*)", so that they are never dropped. Yara requires the rule sets to refer to existing rules.
+func (p *Pruner) Protect(rs *ast.RuleSet) {
+	p.protected = append(p.protected, protectedRules(rs.Rules)...)
+}
+
+// Prune prunes the given rule set in place and returns the identifiers of the dropped rules. The rules referenced by
+// the rule sets of the given rule set are protected first.
+func (p *Pruner) Prune(rs *ast.RuleSet) []string {
+	p.Protect(rs)
+
+	var dropped []string
+	rules := rs.Rules[:0]
+	for _, rule := range rs.Rules {
+		if rule == nil || rule.Condition == nil {
+			rules = append(rules, rule)
+			continue
+		}
+
+		folded := p.fold(rule.Condition, 1)
+		if isFalse(folded) && !rule.Global && !isProtected(p.protected, rule.Identifier) {
+			p.dropped[rule.Identifier] = struct{}{}
+			dropped = append(dropped, rule.Identifier)
+			continue
+		}
+		if countStringRefs(folded, 1) == countStringRefs(rule.Condition, 1) {
+			if g, ok := folded.(*ast.Group); ok {
+				folded = g.Expression
+			}
+			rule.Condition = folded
+		} else {
+			// Folding removed some string references, which would fail with unreferenced string error. Only the
+			// references to the dropped rules are replaced in this case.
+			rule.Condition = p.replaceDropped(rule.Condition, 1)
+		}
+		rules = append(rules, rule)
+	}
+	rs.Rules = rules
+	return dropped
+}
+
+// fold substitutes the constants and the dropped rule references, then simplifies the boolean expressions.
+func (p *Pruner) fold(expr ast.Expression, depth int) ast.Expression {
+	if expr == nil || depth > depthLimit {
+		return expr
+	}
+
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		return p.substitute(e)
+	case *ast.Group:
+		inner := p.fold(e.Expression, depth+1)
+		if isBool(inner) || isLiteral(inner) {
+			return inner
+		}
+		return &ast.Group{Expression: inner}
+	case *ast.Not:
+		inner := p.fold(e.Expression, depth+1)
+		if isBool(inner) {
+			return boolKeyword(isFalse(inner))
+		}
+		return &ast.Not{Expression: inner}
+	case *ast.Operation:
+		return p.foldOperation(e, depth)
+	}
+	return p.replaceDropped(expr, depth)
+}
+
+func (p *Pruner) foldOperation(op *ast.Operation, depth int) ast.Expression {
+	operands := make([]ast.Expression, 0, len(op.Operands))
+	for _, operand := range op.Operands {
+		operands = append(operands, p.fold(operand, depth+1))
+	}
+
+	switch op.Operator {
+	case ast.OpAnd, ast.OpOr:
+		// short is the value which determines the result alone, false for "and" and true for "or".
+		short := op.Operator == ast.OpOr
+		kept := operands[:0]
+		for _, operand := range operands {
+			if isBool(operand) {
+				if isTrue(operand) == short {
+					return boolKeyword(short)
+				}
+				continue
+			}
+			kept = append(kept, operand)
+		}
+		switch len(kept) {
+		case 0:
+			return boolKeyword(!short)
+		case 1:
+			if _, ok := kept[0].(*ast.Operation); ok {
+				return &ast.Group{Expression: kept[0]}
+			}
+			return kept[0]
+		}
+		return &ast.Operation{Operator: op.Operator, Operands: kept}
+	case ast.OpEqual, ast.OpNotEqual:
+		if len(operands) == 2 {
+			if eq, ok := literalsEqual(operands[0], operands[1]); ok {
+				return boolKeyword(eq == (op.Operator == ast.OpEqual))
+			}
+		}
+	}
+	return &ast.Operation{Operator: op.Operator, Operands: operands}
+}
+
+func (p *Pruner) substitute(ident *ast.Identifier) ast.Expression {
+	if lit, ok := p.consts[ident.Identifier]; ok {
+		return lit
+	}
+	if _, ok := p.dropped[ident.Identifier]; ok {
+		return ast.KeywordFalse
+	}
+	return ident
+}
+
+// replaceDropped replaces the references to the dropped rules and the constant variables in the given expression
+// without folding it. Rule references may appear in any expression, therefore all expression types having
+// sub-expressions are visited.
+func (p *Pruner) replaceDropped(expr ast.Expression, depth int) ast.Expression {
+	if expr == nil || depth > depthLimit {
+		return expr
+	}
+
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		return p.substitute(e)
+	case *ast.Group:
+		return &ast.Group{Expression: p.replaceDropped(e.Expression, depth+1)}
+	case *ast.Not:
+		return &ast.Not{Expression: p.replaceDropped(e.Expression, depth+1)}
+	case *ast.Defined:
+		return &ast.Defined{Expression: p.replaceDropped(e.Expression, depth+1)}
+	case *ast.Minus:
+		return &ast.Minus{Expression: p.replaceDropped(e.Expression, depth+1)}
+	case *ast.BitwiseNot:
+		return &ast.BitwiseNot{Expression: p.replaceDropped(e.Expression, depth+1)}
+	case *ast.Percentage:
+		return &ast.Percentage{Expression: p.replaceDropped(e.Expression, depth+1)}
+	case *ast.Operation:
+		operands := make([]ast.Expression, 0, len(e.Operands))
+		for _, operand := range e.Operands {
+			operands = append(operands, p.replaceDropped(operand, depth+1))
+		}
+		return &ast.Operation{Operator: e.Operator, Operands: operands}
+	case *ast.Subscripting:
+		return &ast.Subscripting{
+			Array: e.Array,
+			Index: p.replaceDropped(e.Index, depth+1),
+		}
+	case *ast.FunctionCall:
+		args := make([]ast.Expression, 0, len(e.Arguments))
+		for _, arg := range e.Arguments {
+			args = append(args, p.replaceDropped(arg, depth+1))
+		}
+		return &ast.FunctionCall{Callable: e.Callable, Arguments: args, Builtin: e.Builtin}
+	case *ast.ForIn:
+		return &ast.ForIn{
+			Quantifier: p.replaceDropped(e.Quantifier, depth+1),
+			Variables:  e.Variables,
+			Iterator:   e.Iterator,
+			Condition:  p.replaceDropped(e.Condition, depth+1),
+		}
+	case *ast.ForOf:
+		return &ast.ForOf{
+			Quantifier: p.replaceDropped(e.Quantifier, depth+1),
+			Strings:    e.Strings,
+			Condition:  p.replaceDropped(e.Condition, depth+1),
+		}
+	case *ast.Of:
+		// The rules of the rule set are kept as they are, they are protected from being dropped.
+		of := &ast.Of{
+			Quantifier:  p.replaceDropped(e.Quantifier, depth+1),
+			Strings:     e.Strings,
+			Rules:       e.Rules,
+			TextStrings: e.TextStrings,
+			At:          p.replaceDropped(e.At, depth+1),
+		}
+		if e.In != nil {
+			of.In = &ast.Range{
+				Start: p.replaceDropped(e.In.Start, depth+1),
+				End:   p.replaceDropped(e.In.End, depth+1),
+			}
+		}
+		return of
+	}
+	return expr
+}
+
+// protectedRules returns the rule identifiers and wildcard prefixes referenced by the rule sets, such as
+// "any of (a, b*)". These rules cannot be dropped since rule sets must only refer to existing rules.
+func protectedRules(rules []*ast.Rule) []string {
+	var names []string
+	for _, rule := range rules {
+		if rule == nil {
+			continue
+		}
+		walkNodes(rule.Condition, 1, func(n ast.Node) {
+			of, ok := n.(*ast.Of)
+			if !ok || of.Rules == nil {
+				return
+			}
+			walkNodes(of.Rules, 1, func(n ast.Node) {
+				if ident, ok := n.(*ast.Identifier); ok {
+					names = append(names, ident.Identifier)
+				}
+			})
+		})
+	}
+	return names
+}
+
+func isProtected(protected []string, name string) bool {
+	for _, p := range protected {
+		if p == name || (strings.HasSuffix(p, "*") && strings.HasPrefix(name, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// countStringRefs counts the nodes referring to the rule's strings.
+func countStringRefs(node ast.Node, depth int) int {
+	count := 0
+	walkNodes(node, depth, func(n ast.Node) {
+		switch v := n.(type) {
+		case *ast.StringIdentifier, *ast.StringCount, *ast.StringOffset, *ast.StringLength:
+			count++
+		case ast.Keyword:
+			if v == ast.KeywordThem {
+				count++
+			}
+		case *ast.Of:
+			if len(v.TextStrings) > 0 {
+				count++
+			}
+		}
+	})
+	return count
+}
+
+func walkNodes(node ast.Node, depth int, fn func(ast.Node)) {
+	if node == nil || depth > depthLimit {
+		return
+	}
+	fn(node)
+	for _, n := range node.Children() {
+		walkNodes(n, depth+1, fn)
+	}
+}
+
+func literalOf(value interface{}) ast.Expression {
+	switch v := value.(type) {
+	case bool:
+		return boolKeyword(v)
+	case string:
+		return &ast.LiteralString{Value: v}
+	case int64:
+		return &ast.LiteralInteger{Value: v}
+	case int:
+		return &ast.LiteralInteger{Value: int64(v)}
+	}
+	return nil
+}
+
+// literalsEqual compares two literal expressions. It returns false as second value if they cannot be compared.
+func literalsEqual(a, b ast.Expression) (bool, bool) {
+	switch x := a.(type) {
+	case *ast.LiteralString:
+		if y, ok := b.(*ast.LiteralString); ok {
+			return x.Value == y.Value, true
+		}
+	case *ast.LiteralInteger:
+		if y, ok := b.(*ast.LiteralInteger); ok {
+			return x.Value == y.Value, true
+		}
+	case ast.Keyword:
+		if isBool(x) && isBool(b) {
+			return x == b.(ast.Keyword), true
+		}
+	}
+	return false, false
+}
+
+func boolKeyword(v bool) ast.Keyword {
+	if v {
+		return ast.KeywordTrue
+	}
+	return ast.KeywordFalse
+}
+
+func isTrue(expr ast.Expression) bool {
+	k, ok := expr.(ast.Keyword)
+	return ok && k == ast.KeywordTrue
+}
+
+func isFalse(expr ast.Expression) bool {
+	k, ok := expr.(ast.Keyword)
+	return ok && k == ast.KeywordFalse
+}
+
+func isBool(expr ast.Expression) bool {
+	return isTrue(expr) || isFalse(expr)
+}
+
+func isLiteral(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.LiteralString, *ast.LiteralInteger:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package variables_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/VirusTotal/gyp/parser"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binalyze/gora/variables"
+)
+
+var linuxConsts = map[variables.VariableType]interface{}{
+	variables.VarOs:        "linux",
+	variables.VarOsLinux:   true,
+	variables.VarOsWindows: false,
+	variables.VarOsDarwin:  false,
+	variables.VarOsAIX:     false,
+}
+
+func pruneString(t *testing.T, p *variables.Pruner, rules string) (string, []string) {
+	t.Helper()
+	rs, dropped, err := p.PruneReader(strings.NewReader(rules))
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	require.NoError(t, rs.WriteSource(&sb))
+	return sb.String(), dropped
+}
+
+func TestPruner_Prune(t *testing.T) {
+	p := variables.NewPruner(linuxConsts)
+	src, dropped := pruneString(t, p, `
+	rule windows_only { condition: os_windows and filesize < 100 }
+	rule aix_only { condition: os == "aix" }
+	rule linux_only { condition: os_linux and filesize < 100 }
+	rule not_darwin { condition: not os_darwin or file_path == "" }
+	rule any_unix { condition: (os_linux or os_darwin) and file_name == "x" }
+	rule ref { condition: windows_only or filesize > 10 }
+	`)
+
+	require.Equal(t, []string{"windows_only", "aix_only"}, dropped)
+	require.NotContains(t, src, "windows_only")
+	require.NotContains(t, src, "aix_only")
+	require.Contains(t, src, "rule linux_only {\n  condition:\n    filesize < 100\n}")
+	require.Contains(t, src, "rule not_darwin {\n  condition:\n    true\n}")
+	require.Contains(t, src, "rule any_unix {\n  condition:\n    file_name == \"x\"\n}")
+	require.Contains(t, src, "rule ref {\n  condition:\n    filesize > 10\n}")
+
+	// Dropped rules of the previous calls are still replaced.
+	src, dropped = pruneString(t, p, `rule ref2 { condition: aix_only or filesize > 10 }`)
+	require.Empty(t, dropped)
+	require.Contains(t, src, "filesize > 10")
+}
+
+func TestPruner_PruneKeepsSemantics(t *testing.T) {
+	p := variables.NewPruner(linuxConsts)
+	src, dropped := pruneString(t, p, `
+	global rule global_windows { condition: os_windows }
+	rule set_member { condition: os_darwin }
+	rule set_user { condition: any of (set_*) }
+	rule strings_kept {
+		strings:
+			$a = "abc"
+		condition:
+			os_linux or $a
+	}
+	`)
+
+	require.Empty(t, dropped)
+	require.Contains(t, src, "global rule global_windows {\n  condition:\n    false\n}")
+	require.Contains(t, src, "rule set_member {")
+	require.Contains(t, src, "true or $a")
+}
+
+func TestPruner_Protect(t *testing.T) {
+	p := variables.NewPruner(linuxConsts)
+	first := `
+	rule set_member { condition: os_darwin }
+	rule dropped { condition: os_windows }
+	`
+	// The rule set matches the rules of the first rule set too, dropping them would change "all of" to be true.
+	second := `
+	rule set_local { condition: filesize > 0 }
+	rule set_user { condition: all of (set_*) and (dropped or filesize > 10) }
+	rule strings_user {
+		strings:
+			$a = "abc"
+		condition:
+			(os_linux or $a) and all of (set_*) and not dropped
+	}
+	`
+	for _, rules := range []string{first, second} {
+		rs, err := parser.Parse(strings.NewReader(rules))
+		require.NoError(t, err)
+		p.Protect(rs)
+	}
+
+	src, dropped := pruneString(t, p, first)
+	require.Equal(t, []string{"dropped"}, dropped)
+	require.Contains(t, src, "rule set_member {")
+
+	src, dropped = pruneString(t, p, second)
+	require.Empty(t, dropped)
+	require.Contains(t, src, "all of (set_*) and ((filesize > 10))")
+	require.Contains(t, src, "(true or $a) and all of (set_*) and not false")
+}
+
+func TestPruner_PruneIncludes(t *testing.T) {
+	p := variables.NewPruner(linuxConsts)
+	src, dropped := pruneString(t, p, `
+	include "other.yar"
+	rule windows_only { condition: os_windows }
+	`)
+	require.Empty(t, dropped)
+	require.Contains(t, src, "os_windows")
+}
+
+func TestHostConstants(t *testing.T) {
+	consts := variables.HostConstants()
+	require.Len(t, consts, 5)
+	for vid, value := range consts {
+		expect, err := variables.Valuers[vid].Value(nil)
+		require.NoError(t, err)
+		require.Equal(t, expect, value)
+	}
+}
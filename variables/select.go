@@ -0,0 +1,125 @@
+package variables
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Preset names.
+const (
+	// PresetMinimal selects the variables describing the host and the scan context only.
+	PresetMinimal = "minimal"
+	// PresetFile selects the minimal and the file variables.
+	PresetFile = "file"
+	// PresetProcess selects the minimal, process and memory region variables.
+	PresetProcess = "process"
+	// PresetForensic selects the minimal, file and process variables.
+	PresetForensic = "forensic"
+	// PresetAll selects all available variables.
+	PresetAll = "all"
+	// PresetDefault selects the variables of Defaults, to add the opt-in variables to them.
+	PresetDefault = "default"
+)
+
+// presets holds the selectors of the presets. Presets are defined using patterns to include new variables
+// automatically.
+var presets = map[string][]string{
+	PresetMinimal:  {"os", "os_*", "in_*", "time_now"},
+	PresetFile:     {PresetMinimal, "file_*"},
+	PresetProcess:  {PresetMinimal, "process_*", "region_*"},
+	PresetForensic: {PresetFile, PresetProcess},
+	PresetAll:      {"*"},
+}
+
+func init() {
+	for _, v := range Defaults() {
+		presets[PresetDefault] = append(presets[PresetDefault], v.String())
+	}
+}
+
+// Presets returns the names of the available presets.
+func Presets() []string {
+	return []string{PresetMinimal, PresetFile, PresetProcess, PresetForensic, PresetAll, PresetDefault}
+}
+
+// Preset returns the variables of the given preset. It returns false as second value if there is no such preset.
+func Preset(name string) ([]VariableType, bool) {
+	if _, ok := presets[name]; !ok {
+		return nil, false
+	}
+	vars, err := Select(name)
+	return vars, err == nil
+}
+
+// Select returns the variables selected by the given selectors in the order of List. A selector is a preset name, a
+// variable name or a glob pattern of variable names such as "file_*". Selectors are applied in the given order, and a
+// selector prefixed with "!" removes the matching variables from the selection, such as "!process_command_line". If
+// the first selector is a removal, the selection starts with all variables.
+//
+// An error wrapping ErrUnknownVariable is returned if a selector matches neither a preset nor a variable.
+//
+// Note that the rules referring to the variables not selected cannot be compiled.
+func Select(selectors ...string) ([]VariableType, error) {
+	var selected [typeEnd]bool
+	if len(selectors) > 0 && strings.HasPrefix(selectors[0], "!") {
+		for v := VariableType(1); v < typeEnd; v++ {
+			selected[v] = true
+		}
+	}
+
+	if err := applySelectors(&selected, selectors, 1); err != nil {
+		return nil, err
+	}
+
+	vars := make([]VariableType, 0, typeEnd)
+	for _, v := range List() {
+		if selected[v] {
+			vars = append(vars, v)
+		}
+	}
+	return vars, nil
+}
+
+func applySelectors(selected *[typeEnd]bool, selectors []string, depth int) error {
+	if depth > len(presets) {
+		return fmt.Errorf("preset recursion limit exceeded")
+	}
+	for _, sel := range selectors {
+		value := true
+		if strings.HasPrefix(sel, "!") {
+			value = false
+			sel = sel[1:]
+		}
+		sel = strings.TrimSpace(sel)
+
+		if preset, ok := presets[sel]; ok {
+			var presetSelected [typeEnd]bool
+			if err := applySelectors(&presetSelected, preset, depth+1); err != nil {
+				return err
+			}
+			for v, ok := range presetSelected {
+				if ok {
+					selected[v] = value
+				}
+			}
+			continue
+		}
+
+		matched := false
+		for v := VariableType(1); v < typeEnd; v++ {
+			ok, err := path.Match(sel, v.String())
+			if err != nil {
+				return fmt.Errorf("invalid variable pattern %q: %w", sel, err)
+			}
+			if ok {
+				selected[v] = value
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%w: %q", ErrUnknownVariable, sel)
+		}
+	}
+	return nil
+}
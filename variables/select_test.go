@@ -0,0 +1,107 @@
+package variables_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/binalyze/gora/variables"
+)
+
+func TestPreset(t *testing.T) {
+	minimal, ok := Preset(PresetMinimal)
+	require.True(t, ok)
+	require.Equal(t, []VariableType{
+		VarOs, VarOsLinux, VarOsWindows, VarOsDarwin, VarOsAIX, VarInFileSystem, VarInProcess, VarTimeNow,
+	}, minimal)
+
+	file, ok := Preset(PresetFile)
+	require.True(t, ok)
+	require.Subset(t, file, minimal)
+	require.Contains(t, file, VarFileAccessedTime)
+	require.NotContains(t, file, VarProcessName)
+
+	process, ok := Preset(PresetProcess)
+	require.True(t, ok)
+	require.Subset(t, process, minimal)
+	require.Contains(t, process, VarProcessCommandLine)
+	require.NotContains(t, process, VarFilePath)
+
+	all, ok := Preset(PresetAll)
+	require.True(t, ok)
+	require.Equal(t, List(), all)
+
+	for _, name := range Presets() {
+		vars, ok := Preset(name)
+		require.True(t, ok)
+		require.NotEmpty(t, vars)
+	}
+
+	_, ok = Preset("unknown")
+	require.False(t, ok)
+}
+
+func TestDefaults(t *testing.T) {
+	defaults := Defaults()
+	require.Contains(t, defaults, VarProcessCommandLine)
+	require.Contains(t, defaults, VarFileDeleted)
+	require.NotContains(t, defaults, VarFileFsType)
+	require.NotContains(t, defaults, VarProcessSocketCount)
+	for _, v := range List() {
+		require.Equal(t, v.OptIn(), !contains(defaults, v), v.String())
+	}
+
+	preset, ok := Preset(PresetDefault)
+	require.True(t, ok)
+	require.Equal(t, defaults, preset)
+
+	// the opt-in variables are added to the defaults by selecting them.
+	vars, err := Select(PresetDefault, "file_package_*")
+	require.NoError(t, err)
+	require.Len(t, vars, len(defaults)+3)
+	require.Contains(t, vars, VarFilePackageModified)
+}
+
+func contains(vars []VariableType, v VariableType) bool {
+	for _, x := range vars {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSelect(t *testing.T) {
+	vars, err := Select("file_path", "file_name", "file_path")
+	require.NoError(t, err)
+	require.Equal(t, []VariableType{VarFilePath, VarFileName}, vars)
+
+	vars, err = Select("!process_command_line", "!process_user_*")
+	require.NoError(t, err)
+	require.Len(t, vars, len(List())-3)
+	require.NotContains(t, vars, VarProcessCommandLine)
+	require.NotContains(t, vars, VarProcessUserSid)
+
+	vars, err = Select(PresetMinimal, "!os_*", "file_*_time")
+	require.NoError(t, err)
+	require.Equal(t, []VariableType{
+		VarOs, VarInFileSystem, VarInProcess, VarTimeNow,
+		VarFileModifiedTime, VarFileAccessedTime, VarFileChangedTime, VarFileBirthTime,
+	}, vars)
+
+	vars, err = Select(PresetForensic, "!"+PresetProcess)
+	require.NoError(t, err)
+	require.NotContains(t, vars, VarOs)
+	require.Contains(t, vars, VarFilePath)
+
+	vars, err = Select()
+	require.NoError(t, err)
+	require.Empty(t, vars)
+
+	_, err = Select("no_such_*")
+	require.True(t, errors.Is(err, ErrUnknownVariable))
+
+	_, err = Select("file_[")
+	require.Error(t, err)
+}
@@ -0,0 +1,186 @@
+package variables
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// ErrValuerTimeout is wrapped by the value errors of the Valuers exceeding the valuer timeout. See
+// Variables.SetValuerTimeout.
+var ErrValuerTimeout = errors.New("valuer timeout")
+
+type (
+	// ValueErrorHandler is the signature of ScanContext.HandleValueError. It is called with the error of a Valuer
+	// after the default value of the variable is defined. Returning an error aborts defining the variables.
+	ValueErrorHandler func(VariableDefiner, VariableType, error) error
+
+	// ValueError is the error passed to the value error handlers. It wraps the Valuer error with the variable and the
+	// scan target.
+	ValueError struct {
+		Variable VariableType
+		// Path is the file or process path of the scan target, if known.
+		Path string
+		// Pid is the process id of the scan target, if known.
+		Pid int
+		Err error
+	}
+
+	// DefaultRecorder is an optional interface for ScanContext implementations. If it is implemented,
+	// Variables.DefineScannerVariables calls RecordDefault for each variable defined with its default value, with
+	// the Valuer error if there is one.
+	DefaultRecorder interface {
+		RecordDefault(VariableType, error)
+	}
+
+	// ValueSummary summarizes the variables defined with their default values in a scan.
+	ValueSummary struct {
+		// Defaults holds the variables defined with their default values, either because their values are not
+		// available for the scan target or their Valuers failed.
+		Defaults []VariableType
+		// Errors holds the errors of the failed Valuers.
+		Errors []*ValueError
+	}
+
+	// ValueErrorCollector is a value error handler which collects the errors and continues. It is safe for
+	// concurrent use.
+	ValueErrorCollector struct {
+		mu   sync.Mutex
+		errs []*ValueError
+	}
+)
+
+// Error implements the error interface.
+func (e *ValueError) Error() string {
+	target := e.Path
+	if e.Pid > 0 {
+		target = "pid " + strconv.Itoa(e.Pid)
+		if e.Path != "" {
+			target += " '" + e.Path + "'"
+		}
+	}
+	if target == "" {
+		return fmt.Sprintf("variable %s value error: %v", e.Variable, e.Err)
+	}
+	return fmt.Sprintf("variable %s value error for %s: %v", e.Variable, target, e.Err)
+}
+
+// Unwrap returns the Valuer error.
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+// IgnoreValueErrors is a value error handler which ignores all errors. Variables are defined with their default
+// values.
+func IgnoreValueErrors(VariableDefiner, VariableType, error) error {
+	return nil
+}
+
+// LogValueErrors returns a value error handler which logs the errors using the given printf like function, such as
+// log.Printf, and continues.
+func LogValueErrors(logf func(format string, v ...interface{})) ValueErrorHandler {
+	return func(_ VariableDefiner, _ VariableType, err error) error {
+		logf("%v", err)
+		return nil
+	}
+}
+
+// FailOnValueErrors returns a value error handler which returns the errors of the given variables, and ignores the
+// others.
+func FailOnValueErrors(vars ...VariableType) ValueErrorHandler {
+	var fail [typeEnd]bool
+	for _, v := range vars {
+		if v < typeEnd {
+			fail[v] = true
+		}
+	}
+	return func(_ VariableDefiner, v VariableType, err error) error {
+		if v < typeEnd && fail[v] {
+			return err
+		}
+		return nil
+	}
+}
+
+// Handle is a value error handler which collects the error and continues.
+func (c *ValueErrorCollector) Handle(_ VariableDefiner, v VariableType, err error) error {
+	var verr *ValueError
+	if !errors.As(err, &verr) {
+		verr = &ValueError{Variable: v, Err: err}
+	}
+	c.mu.Lock()
+	c.errs = append(c.errs, verr)
+	c.mu.Unlock()
+	return nil
+}
+
+// Errors returns the collected errors.
+func (c *ValueErrorCollector) Errors() []*ValueError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	errs := make([]*ValueError, len(c.errs))
+	copy(errs, c.errs)
+	return errs
+}
+
+// Err returns the collected errors joined, or nil if there is no error.
+func (c *ValueErrorCollector) Err() error {
+	errs := c.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+	joined := make([]error, 0, len(errs))
+	for _, err := range errs {
+		joined = append(joined, err)
+	}
+	return errors.Join(joined...)
+}
+
+// Reset removes the collected errors.
+func (c *ValueErrorCollector) Reset() {
+	c.mu.Lock()
+	c.errs = nil
+	c.mu.Unlock()
+}
+
+// RecordDefault implements the DefaultRecorder interface.
+func (s *ValueSummary) RecordDefault(v VariableType, err error) {
+	s.Defaults = append(s.Defaults, v)
+	if err == nil {
+		return
+	}
+	var verr *ValueError
+	if !errors.As(err, &verr) {
+		verr = &ValueError{Variable: v, Err: err}
+	}
+	s.Errors = append(s.Errors, verr)
+}
+
+// IsDefault reports whether the given variable is defined with its default value.
+func (s *ValueSummary) IsDefault(v VariableType) bool {
+	for _, d := range s.Defaults {
+		if d == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset resets the summary to be reused for another scan.
+func (s *ValueSummary) Reset() {
+	s.Defaults = s.Defaults[:0]
+	s.Errors = s.Errors[:0]
+}
+
+// Copy creates a deep copy of the summary.
+func (s *ValueSummary) Copy() ValueSummary {
+	c := ValueSummary{}
+	if len(s.Defaults) > 0 {
+		c.Defaults = append([]VariableType(nil), s.Defaults...)
+	}
+	if len(s.Errors) > 0 {
+		c.Errors = append([]*ValueError(nil), s.Errors...)
+	}
+	return c
+}
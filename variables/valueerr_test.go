@@ -0,0 +1,90 @@
+package variables_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/binalyze/gora/variables"
+)
+
+func TestValueError(t *testing.T) {
+	errTest := errors.New("test error")
+
+	err := &ValueError{Variable: VarProcessName, Pid: 10, Path: "/bin/a", Err: errTest}
+	require.ErrorIs(t, err, errTest)
+	require.Equal(t, "variable process_name value error for pid 10 '/bin/a': test error", err.Error())
+
+	err = &ValueError{Variable: VarFileName, Path: "/a", Err: errTest}
+	require.Equal(t, "variable file_name value error for /a: test error", err.Error())
+
+	err = &ValueError{Variable: VarFileName, Err: errTest}
+	require.Equal(t, "variable file_name value error: test error", err.Error())
+}
+
+func TestValueErrorHandlers(t *testing.T) {
+	errTest := errors.New("test error")
+
+	require.NoError(t, IgnoreValueErrors(nil, VarFilePath, errTest))
+
+	var logged string
+	logFn := LogValueErrors(func(format string, v ...interface{}) {
+		logged = fmt.Sprintf(format, v...)
+	})
+	require.NoError(t, logFn(nil, VarFilePath, errTest))
+	require.Equal(t, "test error", logged)
+
+	failFn := FailOnValueErrors(VarProcessCommandLine)
+	require.NoError(t, failFn(nil, VarFilePath, errTest))
+	require.Same(t, errTest, failFn(nil, VarProcessCommandLine, errTest))
+
+	var c ValueErrorCollector
+	require.NoError(t, c.Err())
+	require.NoError(t, c.Handle(nil, VarFilePath, errTest))
+	require.NoError(t, c.Handle(nil, VarFileName, &ValueError{Variable: VarFileName, Err: errTest}))
+	require.Len(t, c.Errors(), 2)
+	require.Equal(t, VarFilePath, c.Errors()[0].Variable)
+	require.ErrorIs(t, c.Err(), errTest)
+	c.Reset()
+	require.Empty(t, c.Errors())
+}
+
+func TestValueSummary(t *testing.T) {
+	orig := Valuers
+	t.Cleanup(func() {
+		Valuers = orig
+	})
+
+	errTest := errors.New("test error")
+	Valuers[VarProcessName] = ValueFunc(func(_ ScanContext) (interface{}, error) {
+		return nil, errTest
+	})
+
+	var collector ValueErrorCollector
+	var sctx ScanContextImpl
+	sctx.SetPid(10)
+	sctx.SetHandleValueError(collector.Handle)
+
+	scanner := new(variableDefinerMock)
+	scanner.On("DefineVariable", VarFileModifiedTime.String(), int64(0)).Return(nil).Times(1)
+	scanner.On("DefineVariable", VarProcessId.String(), int64(10)).Return(nil).Times(1)
+	scanner.On("DefineVariable", VarProcessName.String(), "").Return(nil).Times(1)
+
+	var vr Variables
+	vr.InitVariables([]VariableType{VarFileModifiedTime, VarProcessId, VarProcessName})
+	require.NoError(t, vr.DefineScannerVariables(&sctx, scanner))
+	scanner.AssertExpectations(t)
+
+	summary := sctx.ValueSummary()
+	require.Equal(t, []VariableType{VarFileModifiedTime, VarProcessName}, summary.Defaults)
+	require.True(t, summary.IsDefault(VarFileModifiedTime))
+	require.False(t, summary.IsDefault(VarProcessId))
+	require.Len(t, summary.Errors, 1)
+	require.Equal(t, &ValueError{Variable: VarProcessName, Pid: 10, Err: errTest}, summary.Errors[0])
+	require.Equal(t, summary.Errors, collector.Errors())
+
+	sctx.Reset()
+	require.Empty(t, sctx.ValueSummary().Defaults)
+}
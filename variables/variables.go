@@ -2,6 +2,7 @@ package variables
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"path/filepath"
@@ -9,8 +10,6 @@ import (
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/djherbis/times"
 )
 
 type (
@@ -18,7 +17,20 @@ type (
 	// Variables holds the list of applicable variables to define external variables for yara compiler and scanner, and
 	// it provides methods to set values for the yara compiler and scanner.
 	Variables struct {
-		list []VariableType
+		list          []VariableType
+		valuerTimeout time.Duration
+	}
+
+	// Values holds the values of the variables of a scan target calculated by their Valuers, so each Valuer is called
+	// once when the values are used by both the Prefilter and DefineScannerValues. The values are calculated on first
+	// use, with the valuer timeout of the Variables creating it. It is not safe for concurrent use.
+	Values struct {
+		vr        *Variables
+		sCtx      ScanContext
+		valuerCtx ScanContext
+		values    [typeEnd]interface{}
+		errs      [typeEnd]error
+		done      [typeEnd]bool
 	}
 
 	ProcessInfo interface {
@@ -61,46 +73,88 @@ type (
 	VariableType byte
 	// MetaType represents a metadata of a VariableType.
 	MetaType byte
+	// OSType represents the operating systems supported by a VariableType as bit flags.
+	OSType byte
 )
 
-// Variable types.
+// Variable types. The table below is also available at runtime using Catalog.
 // L = Linux, W = Windows, D = Darwin, A = AIX
 
 const (
 	_ VariableType = iota
-	//                       | Name                 | OS   | Type    | Default | Description                                                   |
-	//                       |----------------------|------|---------|---------|---------------------------------------------------------------|
-	VarOs                 // | os                   | LWDA | String  | ""      | Operating system name, linux, windows, darwin or aix |
-	VarOsLinux            // | os_linux             | LWDA | Boolean | false   | If operating system is linux, its value is true |
-	VarOsWindows          // | os_windows           | LWDA | Boolean | false   | If operating system is Windows, its value is true |
-	VarOsDarwin           // | os_darwin            | LWDA | Boolean | false   | If operating system is Darwin/macOS, its value is true |
-	VarOsAIX              // | os_aix               | LWDA | Boolean | false   | If operating system is AIX, its value is true |
-	VarInFileSystem       // | in_filesystem        | LWDA | Boolean | false   | Determines whether the current scan context is running for the file system. |
-	VarInProcess          // | in_process           | LWDA | Boolean | false   | Determines whether the current scan context is running for the processes. |
-	VarTimeNow            // | time_now             | LWDA | Integer | 0       | Current time in YYYYMMDDHHMMSS format |
-	VarFilePath           // | file_path            | LWDA | String  | ""      | Path of the file |
-	VarFileName           // | file_name            | LWDA | String  | ""      | Name of the file including extension. Example: document.docx |
-	VarFileExtension      // | file_extension       | LWDA | String  | ""      | Extension of the file without leading dot. Example: docx |
-	VarFileReadonly       // | file_readonly        | LWDA | Boolean | false   | If it is a readonly file, its value is true |
-	VarFileHidden         // | file_hidden          | LWDA | Boolean | false   | If it is a hidden file, its value is true |
-	VarFileSystem         // | file_system          |  W   | Boolean | false   | If it is a system file, its value is true |
-	VarFileCompressed     // | file_compressed      |  W   | Boolean | false   | If it is a compressed file, its value is true |
-	VarFileEncrypted      // | file_encrypted       |  W   | Boolean | false   | If it is an encrypted file, its value is true |
-	VarFileModifiedTime   // | file_modified_time   | LWDA | Integer | 0       | File's modification time in YYYYMMDDHHMMSS format |
-	VarFileAccessedTime   // | file_accessed_time   | LWDA | Integer | 0       | File's access time in YYYYMMDDHHMMSS format |
-	VarFileChangedTime    // | file_changed_time    | L DA | Integer | 0       | File's change time in YYYYMMDDHHMMSS format |
-	VarFileBirthTime      // | file_birth_time      |  WD  | Integer | 0       | File's birth time in YYYYMMDDHHMMSS format |
-	VarProcessId          // | process_id           | LWDA | Integer | 0	      | Process's id |
-	VarProcessParentId    // | process_parent_id    | LWDA | Integer | 0       | Parent process id |
-	VarProcessUserName    // | process_user_name    | LWDA | String  | ""      | Process's user name. Windows format: <computer name or domain name>\<user name> |
-	VarProcessUserSid     // | process_user_sid     | LWDA | String  | ""      | Process's user SID. This returns UID of the user as string on Unixes. |
-	VarProcessSessionId   // | process_session_id   | LWDA | Integer | 0       | Process's session id |
-	VarProcessName        // | process_name         | LWDA | String  | ""      | Process's name |
-	VarProcessPath        // | process_path         | LWDA | String  | ""      | Process's path |
-	VarProcessCommandLine // | process_command_line | LWDA | String  | ""      | Process's command line |
+	//                             | Name                        | OS   | Type    | Default | Description                                                   |
+	//                             |-----------------------------|------|---------|---------|---------------------------------------------------------------|
+	VarOs                       // | os                          | LWDA | String  | ""      | Operating system name, linux, windows, darwin or aix |
+	VarOsLinux                  // | os_linux                    | LWDA | Boolean | false   | If operating system is linux, its value is true |
+	VarOsWindows                // | os_windows                  | LWDA | Boolean | false   | If operating system is Windows, its value is true |
+	VarOsDarwin                 // | os_darwin                   | LWDA | Boolean | false   | If operating system is Darwin/macOS, its value is true |
+	VarOsAIX                    // | os_aix                      | LWDA | Boolean | false   | If operating system is AIX, its value is true |
+	VarInFileSystem             // | in_filesystem               | LWDA | Boolean | false   | Determines whether the current scan context is running for the file system. |
+	VarInProcess                // | in_process                  | LWDA | Boolean | false   | Determines whether the current scan context is running for the processes. |
+	VarTimeNow                  // | time_now                    | LWDA | Integer | 0       | Current time in YYYYMMDDHHMMSS format |
+	VarFilePath                 // | file_path                   | LWDA | String  | ""      | Path of the file |
+	VarFileName                 // | file_name                   | LWDA | String  | ""      | Name of the file including extension. Example: document.docx |
+	VarFileExtension            // | file_extension              | LWDA | String  | ""      | Extension of the file without leading dot. Example: docx |
+	VarFileReadonly             // | file_readonly               | LWDA | Boolean | false   | If it is a readonly file, its value is true |
+	VarFileHidden               // | file_hidden                 | LWDA | Boolean | false   | If it is a hidden file, its value is true |
+	VarFileSystem               // | file_system                 |  W   | Boolean | false   | If it is a system file, its value is true |
+	VarFileCompressed           // | file_compressed             |  W   | Boolean | false   | If it is a compressed file, its value is true |
+	VarFileEncrypted            // | file_encrypted              |  W   | Boolean | false   | If it is an encrypted file, its value is true |
+	VarFileModifiedTime         // | file_modified_time          | LWDA | Integer | 0       | File's modification time in YYYYMMDDHHMMSS format |
+	VarFileAccessedTime         // | file_accessed_time          | LWDA | Integer | 0       | File's access time in YYYYMMDDHHMMSS format |
+	VarFileChangedTime          // | file_changed_time           | L DA | Integer | 0       | File's change time in YYYYMMDDHHMMSS format |
+	VarFileBirthTime            // | file_birth_time             |  WD  | Integer | 0       | File's birth time in YYYYMMDDHHMMSS format |
+	VarProcessId                // | process_id                  | LWDA | Integer | 0       | Process's id |
+	VarProcessParentId          // | process_parent_id           | LWDA | Integer | 0       | Parent process id |
+	VarProcessUserName          // | process_user_name           | LWDA | String  | ""      | Process's user name. Windows format: <computer name or domain name>\<user name> |
+	VarProcessUserSid           // | process_user_sid            | LWDA | String  | ""      | Process's user SID. This returns UID of the user as string on Unixes. |
+	VarProcessSessionId         // | process_session_id          | LWDA | Integer | 0       | Process's session id |
+	VarProcessName              // | process_name                | LWDA | String  | ""      | Process's name |
+	VarProcessPath              // | process_path                | LWDA | String  | ""      | Process's path |
+	VarProcessCommandLine       // | process_command_line        | LWDA | String  | ""      | Process's command line |
+	VarRegionPath               // | region_path                 | L    | String  | ""      | Backing path of the scanned memory region, or its pseudo path such as [heap] |
+	VarRegionPerms              // | region_perms                | L    | String  | ""      | Permissions of the scanned memory region as in /proc/<pid>/maps. Example: r-xp |
+	VarRegionAnonymous          // | region_anonymous            | L    | Boolean | false   | If the scanned memory region is not backed by a file, its value is true |
+	VarRegionBase               // | region_base                 | L    | Integer | 0       | Base address of the scanned memory region |
+	VarRegionSize               // | region_size                 | L    | Integer | 0       | Size of the scanned memory region |
+	VarFileDeleted              // | file_deleted                | L DA | Boolean | false   | If the file is deleted but still open or running, its value is true |
+	VarProcessExeDeleted        // | process_exe_deleted         | L    | Boolean | false   | If the executable of the process is deleted, its value is true |
+	VarProcessEnvironment       // | process_environment         | L    | String  | ""      | Process's environment variables as newline separated KEY=VALUE pairs. Disabled unless enabled by Procfs |
+	VarProcessLdPreload         // | process_ld_preload          | L    | String  | ""      | Value of the LD_PRELOAD environment variable of the process. Disabled unless enabled by Procfs |
+	VarProcessLoadedLibraries   // | process_loaded_libraries    | L    | String  | ""      | Newline separated paths of the shared objects mapped executable by the process |
+	VarProcessLibraryCount      // | process_library_count       | L    | Integer | 0       | Number of the shared objects mapped executable by the process |
+	VarProcessListeningPorts    // | process_listening_ports     | L    | String  | ""      | Newline separated listening TCP and bound UDP ports, and listening unix socket paths of the process. Example: tcp/22 or unix//run/app.sock |
+	VarProcessRemoteAddresses   // | process_remote_addresses    | L    | String  | ""      | Newline separated remote addresses of the connected TCP and UDP sockets of the process |
+	VarProcessHasRawSocket      // | process_has_raw_socket      | L    | Boolean | false   | If the process has a raw or packet socket open, its value is true |
+	VarProcessSocketCount       // | process_socket_count        | L    | Integer | 0       | Number of the sockets open by the process |
+	VarProcessCapEffective      // | process_cap_effective       | L    | String  | ""      | Effective capability set of the process in hex as in /proc/<pid>/status. Example: 000001ffffffffff |
+	VarProcessCapEffectiveNames // | process_cap_effective_names | L    | String  | ""      | Comma separated names of the effective capabilities of the process. Example: cap_net_raw,cap_sys_admin |
+	VarProcessCapPermitted      // | process_cap_permitted       | L    | String  | ""      | Permitted capability set of the process in hex as in /proc/<pid>/status |
+	VarProcessCapPermittedNames // | process_cap_permitted_names | L    | String  | ""      | Comma separated names of the permitted capabilities of the process |
+	VarProcessNoNewPrivs        // | process_no_new_privs        | L    | Boolean | false   | If the no_new_privs flag of the process is set, its value is true |
+	VarProcessSeccompMode       // | process_seccomp_mode        | L    | Integer | 0       | Seccomp mode of the process, 0 for disabled, 1 for strict and 2 for filter |
+	VarProcessSelinuxContext    // | process_selinux_context     | L    | String  | ""      | SELinux context of the process. Example: system_u:system_r:init_t:s0 |
+	VarProcessApparmorProfile   // | process_apparmor_profile    | L    | String  | ""      | AppArmor profile of the process. Example: /usr/sbin/cupsd (enforce) |
+	VarProcessIsSetuid          // | process_is_setuid           | L    | Boolean | false   | If the process runs with the ids of a setuid or setgid executable, its value is true |
+	VarFileImmutable            // | file_immutable              | L    | Boolean | false   | If the immutable attribute of the file is set as by chattr +i, its value is true |
+	VarFileAppendOnly           // | file_append_only            | L    | Boolean | false   | If the append only attribute of the file is set as by chattr +a, its value is true |
+	VarFileCapabilities         // | file_capabilities           | L    | String  | ""      | Capabilities of the file as printed by getcap. Example: cap_net_raw=ep |
+	VarFileXattrNames           // | file_xattr_names            | L    | String  | ""      | Newline separated names of the extended attributes of the file |
+	VarFileSelinuxLabel         // | file_selinux_label          | L    | String  | ""      | SELinux label of the file. Example: system_u:object_r:bin_t:s0 |
+	VarFileFsType               // | file_fs_type                | L    | String  | ""      | Type of the file system of the file. Example: ext4 |
+	VarFileMountPoint           // | file_mount_point            | L    | String  | ""      | Mount point of the file system of the file. Example: /dev/shm |
+	VarFileOnTmpfs              // | file_on_tmpfs               | L    | Boolean | false   | If the file is on a tmpfs file system, its value is true |
+	VarFileOnNetworkFs          // | file_on_network_fs          | L    | Boolean | false   | If the file is on a network file system such as NFS or CIFS, its value is true |
+	VarFileMountNoexec          // | file_mount_noexec           | L    | Boolean | false   | If the file system of the file is mounted noexec, its value is true |
+	VarFilePackageOwned         // | file_package_owned          | L    | Boolean | false   | If the file is installed by a dpkg or RPM package, its value is true |
+	VarFilePackageName          // | file_package_name           | L    | String  | ""      | Name of the package which installed the file. Example: coreutils |
+	VarFilePackageModified      // | file_package_modified       | L    | Boolean | false   | If the digest of the file differs from the one recorded by the package manager, its value is true. Disabled unless enabled by PackageDB |
 	typeEnd
 )
 
+// firstOptIn is the first opt-in variable. The variables appended after it are opt-in as well.
+const firstOptIn = VarProcessEnvironment
+
 // Meta types.
 const (
 	MetaBool MetaType = 1 << iota
@@ -109,101 +163,360 @@ const (
 	MetaString
 )
 
+// Operating system types.
+const (
+	OSLinux OSType = 1 << iota
+	OSWindows
+	OSDarwin
+	OSAIX
+
+	osAll = OSLinux | OSWindows | OSDarwin | OSAIX
+)
+
 var (
 	// varNames holds the string names of variables.
 	varNames = [typeEnd]string{
-		VarOs:                 "os",
-		VarOsLinux:            "os_linux",
-		VarOsWindows:          "os_windows",
-		VarOsDarwin:           "os_darwin",
-		VarOsAIX:              "os_aix",
-		VarInFileSystem:       "in_filesystem",
-		VarInProcess:          "in_process",
-		VarTimeNow:            "time_now",
-		VarFilePath:           "file_path",
-		VarFileName:           "file_name",
-		VarFileExtension:      "file_extension",
-		VarFileReadonly:       "file_readonly",
-		VarFileHidden:         "file_hidden",
-		VarFileSystem:         "file_system",
-		VarFileCompressed:     "file_compressed",
-		VarFileEncrypted:      "file_encrypted",
-		VarFileModifiedTime:   "file_modified_time",
-		VarFileAccessedTime:   "file_accessed_time",
-		VarFileChangedTime:    "file_changed_time",
-		VarFileBirthTime:      "file_birth_time",
-		VarProcessId:          "process_id",
-		VarProcessParentId:    "process_parent_id",
-		VarProcessUserName:    "process_user_name",
-		VarProcessUserSid:     "process_user_sid",
-		VarProcessSessionId:   "process_session_id",
-		VarProcessName:        "process_name",
-		VarProcessPath:        "process_path",
-		VarProcessCommandLine: "process_command_line",
+		VarOs:                       "os",
+		VarOsLinux:                  "os_linux",
+		VarOsWindows:                "os_windows",
+		VarOsDarwin:                 "os_darwin",
+		VarOsAIX:                    "os_aix",
+		VarInFileSystem:             "in_filesystem",
+		VarInProcess:                "in_process",
+		VarTimeNow:                  "time_now",
+		VarFilePath:                 "file_path",
+		VarFileName:                 "file_name",
+		VarFileExtension:            "file_extension",
+		VarFileReadonly:             "file_readonly",
+		VarFileHidden:               "file_hidden",
+		VarFileSystem:               "file_system",
+		VarFileCompressed:           "file_compressed",
+		VarFileEncrypted:            "file_encrypted",
+		VarFileModifiedTime:         "file_modified_time",
+		VarFileAccessedTime:         "file_accessed_time",
+		VarFileChangedTime:          "file_changed_time",
+		VarFileBirthTime:            "file_birth_time",
+		VarFileDeleted:              "file_deleted",
+		VarFileImmutable:            "file_immutable",
+		VarFileAppendOnly:           "file_append_only",
+		VarFileCapabilities:         "file_capabilities",
+		VarFileXattrNames:           "file_xattr_names",
+		VarFileSelinuxLabel:         "file_selinux_label",
+		VarFileFsType:               "file_fs_type",
+		VarFileMountPoint:           "file_mount_point",
+		VarFileOnTmpfs:              "file_on_tmpfs",
+		VarFileOnNetworkFs:          "file_on_network_fs",
+		VarFileMountNoexec:          "file_mount_noexec",
+		VarFilePackageOwned:         "file_package_owned",
+		VarFilePackageName:          "file_package_name",
+		VarFilePackageModified:      "file_package_modified",
+		VarProcessId:                "process_id",
+		VarProcessParentId:          "process_parent_id",
+		VarProcessUserName:          "process_user_name",
+		VarProcessUserSid:           "process_user_sid",
+		VarProcessCapEffective:      "process_cap_effective",
+		VarProcessCapEffectiveNames: "process_cap_effective_names",
+		VarProcessCapPermitted:      "process_cap_permitted",
+		VarProcessCapPermittedNames: "process_cap_permitted_names",
+		VarProcessNoNewPrivs:        "process_no_new_privs",
+		VarProcessSeccompMode:       "process_seccomp_mode",
+		VarProcessSelinuxContext:    "process_selinux_context",
+		VarProcessApparmorProfile:   "process_apparmor_profile",
+		VarProcessIsSetuid:          "process_is_setuid",
+		VarProcessSessionId:         "process_session_id",
+		VarProcessName:              "process_name",
+		VarProcessPath:              "process_path",
+		VarProcessCommandLine:       "process_command_line",
+		VarProcessExeDeleted:        "process_exe_deleted",
+		VarProcessEnvironment:       "process_environment",
+		VarProcessLdPreload:         "process_ld_preload",
+		VarProcessLoadedLibraries:   "process_loaded_libraries",
+		VarProcessLibraryCount:      "process_library_count",
+		VarProcessListeningPorts:    "process_listening_ports",
+		VarProcessRemoteAddresses:   "process_remote_addresses",
+		VarProcessHasRawSocket:      "process_has_raw_socket",
+		VarProcessSocketCount:       "process_socket_count",
+		VarRegionPath:               "region_path",
+		VarRegionPerms:              "region_perms",
+		VarRegionAnonymous:          "region_anonymous",
+		VarRegionBase:               "region_base",
+		VarRegionSize:               "region_size",
 	}
 
 	// varMetas holds the metadata of all variables.
 	varMetas = [typeEnd]MetaType{
-		VarOs:                 MetaString,
-		VarOsLinux:            MetaBool,
-		VarOsWindows:          MetaBool,
-		VarOsDarwin:           MetaBool,
-		VarOsAIX:              MetaBool,
-		VarInFileSystem:       MetaBool,
-		VarInProcess:          MetaBool,
-		VarTimeNow:            MetaInt,
-		VarFilePath:           MetaString,
-		VarFileName:           MetaString,
-		VarFileExtension:      MetaString,
-		VarFileReadonly:       MetaBool,
-		VarFileHidden:         MetaBool,
-		VarFileSystem:         MetaBool,
-		VarFileCompressed:     MetaBool,
-		VarFileEncrypted:      MetaBool,
-		VarFileModifiedTime:   MetaInt,
-		VarFileAccessedTime:   MetaInt,
-		VarFileChangedTime:    MetaInt,
-		VarFileBirthTime:      MetaInt,
-		VarProcessId:          MetaInt,
-		VarProcessParentId:    MetaInt,
-		VarProcessUserName:    MetaString,
-		VarProcessUserSid:     MetaString,
-		VarProcessSessionId:   MetaInt,
-		VarProcessName:        MetaString,
-		VarProcessPath:        MetaString,
-		VarProcessCommandLine: MetaString,
+		VarOs:                       MetaString,
+		VarOsLinux:                  MetaBool,
+		VarOsWindows:                MetaBool,
+		VarOsDarwin:                 MetaBool,
+		VarOsAIX:                    MetaBool,
+		VarInFileSystem:             MetaBool,
+		VarInProcess:                MetaBool,
+		VarTimeNow:                  MetaInt,
+		VarFilePath:                 MetaString,
+		VarFileName:                 MetaString,
+		VarFileExtension:            MetaString,
+		VarFileReadonly:             MetaBool,
+		VarFileHidden:               MetaBool,
+		VarFileSystem:               MetaBool,
+		VarFileCompressed:           MetaBool,
+		VarFileEncrypted:            MetaBool,
+		VarFileModifiedTime:         MetaInt,
+		VarFileAccessedTime:         MetaInt,
+		VarFileChangedTime:          MetaInt,
+		VarFileBirthTime:            MetaInt,
+		VarFileDeleted:              MetaBool,
+		VarFileImmutable:            MetaBool,
+		VarFileAppendOnly:           MetaBool,
+		VarFileCapabilities:         MetaString,
+		VarFileXattrNames:           MetaString,
+		VarFileSelinuxLabel:         MetaString,
+		VarFileFsType:               MetaString,
+		VarFileMountPoint:           MetaString,
+		VarFileOnTmpfs:              MetaBool,
+		VarFileOnNetworkFs:          MetaBool,
+		VarFileMountNoexec:          MetaBool,
+		VarFilePackageOwned:         MetaBool,
+		VarFilePackageName:          MetaString,
+		VarFilePackageModified:      MetaBool,
+		VarProcessId:                MetaInt,
+		VarProcessParentId:          MetaInt,
+		VarProcessUserName:          MetaString,
+		VarProcessUserSid:           MetaString,
+		VarProcessCapEffective:      MetaString,
+		VarProcessCapEffectiveNames: MetaString,
+		VarProcessCapPermitted:      MetaString,
+		VarProcessCapPermittedNames: MetaString,
+		VarProcessNoNewPrivs:        MetaBool,
+		VarProcessSeccompMode:       MetaInt,
+		VarProcessSelinuxContext:    MetaString,
+		VarProcessApparmorProfile:   MetaString,
+		VarProcessIsSetuid:          MetaBool,
+		VarProcessSessionId:         MetaInt,
+		VarProcessName:              MetaString,
+		VarProcessPath:              MetaString,
+		VarProcessCommandLine:       MetaString,
+		VarProcessExeDeleted:        MetaBool,
+		VarProcessEnvironment:       MetaString,
+		VarProcessLdPreload:         MetaString,
+		VarProcessLoadedLibraries:   MetaString,
+		VarProcessLibraryCount:      MetaInt,
+		VarProcessListeningPorts:    MetaString,
+		VarProcessRemoteAddresses:   MetaString,
+		VarProcessHasRawSocket:      MetaBool,
+		VarProcessSocketCount:       MetaInt,
+		VarRegionPath:               MetaString,
+		VarRegionPerms:              MetaString,
+		VarRegionAnonymous:          MetaBool,
+		VarRegionBase:               MetaInt,
+		VarRegionSize:               MetaInt,
+	}
+
+	// varDescriptions holds the descriptions of all variables.
+	varDescriptions = [typeEnd]string{
+		VarOs:                       "Operating system name, linux, windows, darwin or aix",
+		VarOsLinux:                  "If operating system is linux, its value is true",
+		VarOsWindows:                "If operating system is Windows, its value is true",
+		VarOsDarwin:                 "If operating system is Darwin/macOS, its value is true",
+		VarOsAIX:                    "If operating system is AIX, its value is true",
+		VarInFileSystem:             "Determines whether the current scan context is running for the file system",
+		VarInProcess:                "Determines whether the current scan context is running for the processes",
+		VarTimeNow:                  "Current time in YYYYMMDDHHMMSS format",
+		VarFilePath:                 "Path of the file",
+		VarFileName:                 "Name of the file including extension. Example: document.docx",
+		VarFileExtension:            "Extension of the file without leading dot. Example: docx",
+		VarFileReadonly:             "If it is a readonly file, its value is true",
+		VarFileHidden:               "If it is a hidden file, its value is true",
+		VarFileSystem:               "If it is a system file, its value is true",
+		VarFileCompressed:           "If it is a compressed file, its value is true",
+		VarFileEncrypted:            "If it is an encrypted file, its value is true",
+		VarFileModifiedTime:         "File's modification time in YYYYMMDDHHMMSS format",
+		VarFileAccessedTime:         "File's access time in YYYYMMDDHHMMSS format",
+		VarFileChangedTime:          "File's change time in YYYYMMDDHHMMSS format",
+		VarFileBirthTime:            "File's birth time in YYYYMMDDHHMMSS format",
+		VarFileDeleted:              "If the file is deleted but still open or running, its value is true",
+		VarFileImmutable:            "If the immutable attribute of the file is set as by chattr +i, its value is true",
+		VarFileAppendOnly:           "If the append only attribute of the file is set as by chattr +a, its value is true",
+		VarFileCapabilities:         "Capabilities of the file as printed by getcap. Example: cap_net_raw=ep",
+		VarFileXattrNames:           "Newline separated names of the extended attributes of the file",
+		VarFileSelinuxLabel:         "SELinux label of the file. Example: system_u:object_r:bin_t:s0",
+		VarFileFsType:               "Type of the file system of the file. Example: ext4",
+		VarFileMountPoint:           "Mount point of the file system of the file. Example: /dev/shm",
+		VarFileOnTmpfs:              "If the file is on a tmpfs file system, its value is true",
+		VarFileOnNetworkFs:          "If the file is on a network file system such as NFS or CIFS, its value is true",
+		VarFileMountNoexec:          "If the file system of the file is mounted noexec, its value is true",
+		VarFilePackageOwned:         "If the file is installed by a dpkg or RPM package, its value is true",
+		VarFilePackageName:          "Name of the package which installed the file. Example: coreutils",
+		VarFilePackageModified:      "If the digest of the file differs from the one recorded by the package manager, its value is true. Disabled unless enabled by PackageDB",
+		VarProcessId:                "Process's id",
+		VarProcessParentId:          "Parent process id",
+		VarProcessUserName:          "Process's user name. Windows format: <computer name or domain name>\\<user name>",
+		VarProcessUserSid:           "Process's user SID. This returns UID of the user as string on Unixes",
+		VarProcessCapEffective:      "Effective capability set of the process in hex as in /proc/<pid>/status. Example: 000001ffffffffff",
+		VarProcessCapEffectiveNames: "Comma separated names of the effective capabilities of the process. Example: cap_net_raw,cap_sys_admin",
+		VarProcessCapPermitted:      "Permitted capability set of the process in hex as in /proc/<pid>/status",
+		VarProcessCapPermittedNames: "Comma separated names of the permitted capabilities of the process",
+		VarProcessNoNewPrivs:        "If the no_new_privs flag of the process is set, its value is true",
+		VarProcessSeccompMode:       "Seccomp mode of the process, 0 for disabled, 1 for strict and 2 for filter",
+		VarProcessSelinuxContext:    "SELinux context of the process. Example: system_u:system_r:init_t:s0",
+		VarProcessApparmorProfile:   "AppArmor profile of the process. Example: /usr/sbin/cupsd (enforce)",
+		VarProcessIsSetuid:          "If the process runs with the ids of a setuid or setgid executable, its value is true",
+		VarProcessSessionId:         "Process's session id",
+		VarProcessName:              "Process's name",
+		VarProcessPath:              "Process's path",
+		VarProcessCommandLine:       "Process's command line",
+		VarProcessExeDeleted:        "If the executable of the process is deleted, its value is true",
+		VarProcessEnvironment:       "Process's environment variables as newline separated KEY=VALUE pairs. Disabled unless enabled by Procfs",
+		VarProcessLdPreload:         "Value of the LD_PRELOAD environment variable of the process. Disabled unless enabled by Procfs",
+		VarProcessLoadedLibraries:   "Newline separated paths of the shared objects mapped executable by the process",
+		VarProcessLibraryCount:      "Number of the shared objects mapped executable by the process",
+		VarProcessListeningPorts:    "Newline separated listening TCP and bound UDP ports, and listening unix socket paths of the process. Example: tcp/22 or unix//run/app.sock",
+		VarProcessRemoteAddresses:   "Newline separated remote addresses of the connected TCP and UDP sockets of the process",
+		VarProcessHasRawSocket:      "If the process has a raw or packet socket open, its value is true",
+		VarProcessSocketCount:       "Number of the sockets open by the process",
+		VarRegionPath:               "Backing path of the scanned memory region, or its pseudo path such as [heap]",
+		VarRegionPerms:              "Permissions of the scanned memory region as in /proc/<pid>/maps. Example: r-xp",
+		VarRegionAnonymous:          "If the scanned memory region is not backed by a file, its value is true",
+		VarRegionBase:               "Base address of the scanned memory region",
+		VarRegionSize:               "Size of the scanned memory region",
+	}
+
+	// varOSes holds the operating systems supported by all variables.
+	varOSes = [typeEnd]OSType{
+		VarOs:                       osAll,
+		VarOsLinux:                  osAll,
+		VarOsWindows:                osAll,
+		VarOsDarwin:                 osAll,
+		VarOsAIX:                    osAll,
+		VarInFileSystem:             osAll,
+		VarInProcess:                osAll,
+		VarTimeNow:                  osAll,
+		VarFilePath:                 osAll,
+		VarFileName:                 osAll,
+		VarFileExtension:            osAll,
+		VarFileReadonly:             osAll,
+		VarFileHidden:               osAll,
+		VarFileSystem:               OSWindows,
+		VarFileCompressed:           OSWindows,
+		VarFileEncrypted:            OSWindows,
+		VarFileModifiedTime:         osAll,
+		VarFileAccessedTime:         osAll,
+		VarFileChangedTime:          OSLinux | OSDarwin | OSAIX,
+		VarFileBirthTime:            OSWindows | OSDarwin,
+		VarFileDeleted:              OSLinux | OSDarwin | OSAIX,
+		VarFileImmutable:            OSLinux,
+		VarFileAppendOnly:           OSLinux,
+		VarFileCapabilities:         OSLinux,
+		VarFileXattrNames:           OSLinux,
+		VarFileSelinuxLabel:         OSLinux,
+		VarFileFsType:               OSLinux,
+		VarFileMountPoint:           OSLinux,
+		VarFileOnTmpfs:              OSLinux,
+		VarFileOnNetworkFs:          OSLinux,
+		VarFileMountNoexec:          OSLinux,
+		VarFilePackageOwned:         OSLinux,
+		VarFilePackageName:          OSLinux,
+		VarFilePackageModified:      OSLinux,
+		VarProcessId:                osAll,
+		VarProcessParentId:          osAll,
+		VarProcessUserName:          osAll,
+		VarProcessUserSid:           osAll,
+		VarProcessCapEffective:      OSLinux,
+		VarProcessCapEffectiveNames: OSLinux,
+		VarProcessCapPermitted:      OSLinux,
+		VarProcessCapPermittedNames: OSLinux,
+		VarProcessNoNewPrivs:        OSLinux,
+		VarProcessSeccompMode:       OSLinux,
+		VarProcessSelinuxContext:    OSLinux,
+		VarProcessApparmorProfile:   OSLinux,
+		VarProcessIsSetuid:          OSLinux,
+		VarProcessSessionId:         osAll,
+		VarProcessName:              osAll,
+		VarProcessPath:              osAll,
+		VarProcessCommandLine:       osAll,
+		VarProcessExeDeleted:        OSLinux,
+		VarProcessEnvironment:       OSLinux,
+		VarProcessLdPreload:         OSLinux,
+		VarProcessLoadedLibraries:   OSLinux,
+		VarProcessLibraryCount:      OSLinux,
+		VarProcessListeningPorts:    OSLinux,
+		VarProcessRemoteAddresses:   OSLinux,
+		VarProcessHasRawSocket:      OSLinux,
+		VarProcessSocketCount:       OSLinux,
+		VarRegionPath:               OSLinux,
+		VarRegionPerms:              OSLinux,
+		VarRegionAnonymous:          OSLinux,
+		VarRegionBase:               OSLinux,
+		VarRegionSize:               OSLinux,
 	}
 
 	// Valuers holds the Valuer implementations of all variables.
 	Valuers = [typeEnd]Valuer{
-		VarOs:                 ValueFunc(varOsFunc),
-		VarOsLinux:            ValueFunc(varOsLinuxFunc),
-		VarOsWindows:          ValueFunc(varOsWindowsFunc),
-		VarOsDarwin:           ValueFunc(varOsDarwinFunc),
-		VarOsAIX:              ValueFunc(varOsAIX),
-		VarInFileSystem:       ValueFunc(varInFileSystemFunc),
-		VarInProcess:          ValueFunc(varInProcessFunc),
-		VarTimeNow:            ValueFunc(varTimeNowFunc),
-		VarFilePath:           ValueFunc(varFilePathFunc),
-		VarFileName:           ValueFunc(varFileNameFunc),
-		VarFileExtension:      ValueFunc(varFileExtensionFunc),
-		VarFileReadonly:       ValueFunc(varFileReadonlyFunc),
-		VarFileHidden:         ValueFunc(varFileHiddenFunc),
-		VarFileSystem:         ValueFunc(varFileSystemFunc),
-		VarFileCompressed:     ValueFunc(varFileCompressedFunc),
-		VarFileEncrypted:      ValueFunc(varFileEncryptedFunc),
-		VarFileModifiedTime:   ValueFunc(varFileModifiedTimeFunc),
-		VarFileAccessedTime:   ValueFunc(varFileAccessedTimeFunc),
-		VarFileChangedTime:    ValueFunc(varFileChangedTimeFunc),
-		VarFileBirthTime:      ValueFunc(varFileBirthTimeFunc),
-		VarProcessId:          ValueFunc(varProcessIdFunc),
-		VarProcessParentId:    ValueFunc(varProcessParentIdFunc),
-		VarProcessUserName:    ValueFunc(varProcessUserNameFunc),
-		VarProcessUserSid:     ValueFunc(varProcessUserSidFunc),
-		VarProcessSessionId:   ValueFunc(varProcessSessionIdFunc),
-		VarProcessName:        ValueFunc(varProcessNameFunc),
-		VarProcessPath:        ValueFunc(varFilePathFunc), // FilePath holds the process's path as well.
-		VarProcessCommandLine: ValueFunc(varProcessCommandLineFunc),
+		VarOs:                       ValueFunc(varOsFunc),
+		VarOsLinux:                  ValueFunc(varOsLinuxFunc),
+		VarOsWindows:                ValueFunc(varOsWindowsFunc),
+		VarOsDarwin:                 ValueFunc(varOsDarwinFunc),
+		VarOsAIX:                    ValueFunc(varOsAIX),
+		VarInFileSystem:             ValueFunc(varInFileSystemFunc),
+		VarInProcess:                ValueFunc(varInProcessFunc),
+		VarTimeNow:                  ValueFunc(varTimeNowFunc),
+		VarFilePath:                 ValueFunc(varFilePathFunc),
+		VarFileName:                 ValueFunc(varFileNameFunc),
+		VarFileExtension:            ValueFunc(varFileExtensionFunc),
+		VarFileReadonly:             ValueFunc(varFileReadonlyFunc),
+		VarFileHidden:               ValueFunc(varFileHiddenFunc),
+		VarFileSystem:               ValueFunc(varFileSystemFunc),
+		VarFileCompressed:           ValueFunc(varFileCompressedFunc),
+		VarFileEncrypted:            ValueFunc(varFileEncryptedFunc),
+		VarFileModifiedTime:         ValueFunc(varFileModifiedTimeFunc),
+		VarFileAccessedTime:         ValueFunc(varFileAccessedTimeFunc),
+		VarFileChangedTime:          ValueFunc(varFileChangedTimeFunc),
+		VarFileBirthTime:            ValueFunc(varFileBirthTimeFunc),
+		VarFileDeleted:              ValueFunc(varFileDeletedFunc),
+		VarFileImmutable:            ValueFunc(varFileImmutableFunc),
+		VarFileAppendOnly:           ValueFunc(varFileAppendOnlyFunc),
+		VarFileCapabilities:         ValueFunc(varFileCapabilitiesFunc),
+		VarFileXattrNames:           ValueFunc(varFileXattrNamesFunc),
+		VarFileSelinuxLabel:         ValueFunc(varFileSelinuxLabelFunc),
+		VarFileFsType:               ValueFunc(varFileFsTypeFunc),
+		VarFileMountPoint:           ValueFunc(varFileMountPointFunc),
+		VarFileOnTmpfs:              ValueFunc(varFileOnTmpfsFunc),
+		VarFileOnNetworkFs:          ValueFunc(varFileOnNetworkFsFunc),
+		VarFileMountNoexec:          ValueFunc(varFileMountNoexecFunc),
+		VarFilePackageOwned:         ValueFunc(varFilePackageOwnedFunc),
+		VarFilePackageName:          ValueFunc(varFilePackageNameFunc),
+		VarFilePackageModified:      ValueFunc(varFilePackageModifiedFunc),
+		VarProcessId:                ValueFunc(varProcessIdFunc),
+		VarProcessParentId:          ValueFunc(varProcessParentIdFunc),
+		VarProcessUserName:          ValueFunc(varProcessUserNameFunc),
+		VarProcessUserSid:           ValueFunc(varProcessUserSidFunc),
+		VarProcessCapEffective:      ValueFunc(varProcessCapEffectiveFunc),
+		VarProcessCapEffectiveNames: ValueFunc(varProcessCapEffectiveNamesFunc),
+		VarProcessCapPermitted:      ValueFunc(varProcessCapPermittedFunc),
+		VarProcessCapPermittedNames: ValueFunc(varProcessCapPermittedNamesFunc),
+		VarProcessNoNewPrivs:        ValueFunc(varProcessNoNewPrivsFunc),
+		VarProcessSeccompMode:       ValueFunc(varProcessSeccompModeFunc),
+		VarProcessSelinuxContext:    ValueFunc(varProcessSelinuxContextFunc),
+		VarProcessApparmorProfile:   ValueFunc(varProcessApparmorProfileFunc),
+		VarProcessIsSetuid:          ValueFunc(varProcessIsSetuidFunc),
+		VarProcessSessionId:         ValueFunc(varProcessSessionIdFunc),
+		VarProcessName:              ValueFunc(varProcessNameFunc),
+		VarProcessPath:              ValueFunc(varFilePathFunc), // FilePath holds the process's path as well.
+		VarProcessCommandLine:       ValueFunc(varProcessCommandLineFunc),
+		VarProcessExeDeleted:        ValueFunc(varProcessExeDeletedFunc),
+		VarProcessEnvironment:       ValueFunc(varProcessEnvironmentFunc),
+		VarProcessLdPreload:         ValueFunc(varProcessLdPreloadFunc),
+		VarProcessLoadedLibraries:   ValueFunc(varProcessLoadedLibrariesFunc),
+		VarProcessLibraryCount:      ValueFunc(varProcessLibraryCountFunc),
+		VarProcessListeningPorts:    ValueFunc(varProcessListeningPortsFunc),
+		VarProcessRemoteAddresses:   ValueFunc(varProcessRemoteAddressesFunc),
+		VarProcessHasRawSocket:      ValueFunc(varProcessHasRawSocketFunc),
+		VarProcessSocketCount:       ValueFunc(varProcessSocketCountFunc),
+		VarRegionPath:               ValueFunc(varRegionPathFunc),
+		VarRegionPerms:              ValueFunc(varRegionPermsFunc),
+		VarRegionAnonymous:          ValueFunc(varRegionAnonymousFunc),
+		VarRegionBase:               ValueFunc(varRegionBaseFunc),
+		VarRegionSize:               ValueFunc(varRegionSizeFunc),
 	}
 )
 
@@ -218,6 +531,23 @@ func List() []VariableType {
 	return list
 }
 
+// Defaults returns the list of the variables defined unless the variables are selected, which are all the available
+// variables except the opt-in ones. It creates a new slice at every call.
+func Defaults() []VariableType {
+	list := make([]VariableType, 0, firstOptIn-1)
+	for v := VariableType(1); v < firstOptIn; v++ {
+		list = append(list, v)
+	}
+	return list
+}
+
+// OptIn reports whether the variable is not in Defaults, since its value is costly to read for every scan target,
+// such as the extended attributes, the mount of the file and the sockets of the process. The opt-in variables are
+// selected using Select or the presets, such as "file_*" or PresetAll.
+func (v VariableType) OptIn() bool {
+	return v >= firstOptIn && v < typeEnd
+}
+
 // Value implements Valuer interface.
 func (fn ValueFunc) Value(sCtx ScanContext) (interface{}, error) {
 	return fn(sCtx)
@@ -257,11 +587,31 @@ func (vr *Variables) DefineCompilerVariables(compiler VariableDefiner) (err erro
 
 // DefineScannerVariables defines the already set variables to the given scanner using their calculated values using
 // their Valuer implementations. Returning error from Valuer's Value method should be handled by the given
-// ScanContext.HandleValueError.
+// ScanContext.HandleValueError, which is called with a *ValueError wrapping the Valuer error with the file path and the
+// process id of the ScanContext. If the ScanContext
+// implements the DefaultRecorder interface, it is notified of the variables defined with their default values.
+//
+// The context of the ScanContext is checked before each Valuer, and its error is returned if it is done. See
+// SetValuerTimeout to limit the time spent in each Valuer.
 func (vr *Variables) DefineScannerVariables(sCtx ScanContext, scanner VariableDefiner) error {
+	return vr.DefineScannerValues(vr.NewValues(sCtx), scanner)
+}
+
+// DefineScannerValues defines the already set variables to the given scanner like DefineScannerVariables, using the
+// given values of the scan target, which may have been calculated already, such as by the Prefilter.
+func (vr *Variables) DefineScannerValues(values *Values, scanner VariableDefiner) error {
+	sCtx := values.sCtx
+	recorder, _ := sCtx.(DefaultRecorder)
+	ctx := sCtx.Context()
+
 	for _, vid := range vr.list {
-		valuer := Valuers[vid]
-		value, err := valuer.Value(sCtx)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		value, err := values.Value(vid)
+		if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			return err
+		}
 
 		if err != nil || value == nil {
 			if e := defineDefaultValue(vid, scanner); e != nil {
@@ -270,6 +620,12 @@ func (vr *Variables) DefineScannerVariables(sCtx ScanContext, scanner VariableDe
 				}
 				return e
 			}
+			if err != nil {
+				err = &ValueError{Variable: vid, Path: sCtx.FilePath(), Pid: sCtx.Pid(), Err: err}
+			}
+			if recorder != nil {
+				recorder.RecordDefault(vid, err)
+			}
 			if err != nil {
 				if err = sCtx.HandleValueError(scanner, vid, err); err != nil {
 					return err
@@ -290,10 +646,86 @@ func (vr *Variables) DefineScannerVariables(sCtx ScanContext, scanner VariableDe
 // This should be used to create new Variables instances for each scanner thread.
 func (vr *Variables) Copy() *Variables {
 	return &Variables{
-		list: vr.Variables(),
+		list:          vr.Variables(),
+		valuerTimeout: vr.valuerTimeout,
 	}
 }
 
+// SetValuerTimeout sets the maximum duration of each Valuer call in DefineScannerVariables. A Valuer exceeding it is
+// abandoned and its variable is defined with the default value, and the value error handler is called with an error
+// wrapping ErrValuerTimeout. Zero, the default, disables the limit.
+//
+// Note that an abandoned Valuer keeps running in the background until it returns, since Valuers cannot be
+// interrupted in general. The Valuers are called with a snapshot of the scan context and of its optional provider
+// interfaces, so the scan context can be reused for the next scan target while they are running.
+func (vr *Variables) SetValuerTimeout(d time.Duration) {
+	vr.valuerTimeout = d
+}
+
+// ValuerTimeout returns the maximum duration of each Valuer call set by SetValuerTimeout.
+func (vr *Variables) ValuerTimeout() time.Duration {
+	return vr.valuerTimeout
+}
+
+// value returns the value of the given variable using its Valuer, limiting its duration by the valuer timeout and the
+// given context.
+func (vr *Variables) value(ctx context.Context, vid VariableType, sCtx ScanContext) (interface{}, error) {
+	valuer := Valuers[vid]
+	if vr.valuerTimeout <= 0 {
+		return valuer.Value(sCtx)
+	}
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := valuer.Value(sCtx)
+		done <- result{value, err}
+	}()
+
+	timer := time.NewTimer(vr.valuerTimeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-timer.C:
+		return nil, fmt.Errorf("%w after %s", ErrValuerTimeout, vr.valuerTimeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// NewValues returns the Values of the scan target of the given ScanContext, which are calculated with the valuer
+// timeout of the Variables. See SetValuerTimeout.
+func (vr *Variables) NewValues(sCtx ScanContext) *Values {
+	values := &Values{vr: vr, sCtx: sCtx, valuerCtx: sCtx}
+	if vr.valuerTimeout > 0 {
+		values.valuerCtx = snapshotScanContext(sCtx)
+	}
+	return values
+}
+
+// ScanContext returns the ScanContext of the scan target of the values.
+func (v *Values) ScanContext() ScanContext {
+	return v.sCtx
+}
+
+// Value returns the value of the given variable and the error of its Valuer. The Valuer is called on first use only.
+// A nil value means the variable is defined with its default value.
+func (v *Values) Value(vid VariableType) (interface{}, error) {
+	if vid == 0 || vid >= typeEnd {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVariable, vid)
+	}
+	if !v.done[vid] {
+		v.done[vid] = true
+		v.values[vid], v.errs[vid] = v.vr.value(v.sCtx.Context(), vid, v.valuerCtx)
+	}
+	return v.values[vid], v.errs[vid]
+}
+
 // Variables returns a copy of variables list.
 func (vr *Variables) Variables() []VariableType {
 	list := make([]VariableType, len(vr.list))
@@ -316,23 +748,26 @@ func (vr *Variables) setVariables(vars []VariableType) {
 }
 
 func defineDefaultValue(vid VariableType, def VariableDefiner) error {
-	var (
-		meta   = vid.Meta()
-		defVal interface{}
-	)
+	defVal := defaultValue(vid)
+	if defVal == nil {
+		return fmt.Errorf("unknown variable: %[1]s(%[1]d)", vid)
+	}
+	return def.DefineVariable(vid.String(), defVal)
+}
 
+// defaultValue returns the default zero value of the variable, or nil if the variable is unknown.
+func defaultValue(vid VariableType) interface{} {
+	meta := vid.Meta()
 	if meta&MetaString != 0 {
-		defVal = ""
+		return ""
 	} else if meta&MetaInt != 0 {
-		defVal = int64(0)
+		return int64(0)
 	} else if meta&MetaBool != 0 {
-		defVal = false
+		return false
 	} else if meta&MetaFloat != 0 {
-		defVal = float64(0)
-	} else {
-		return fmt.Errorf("unknown variable: %[1]s(%[1]d)", vid)
+		return float64(0)
 	}
-	return def.DefineVariable(vid.String(), defVal)
+	return nil
 }
 
 // Define values not to allocate.
@@ -390,20 +825,16 @@ func varInProcessFunc(sCtx ScanContext) (interface{}, error) {
 }
 
 func varFilePathFunc(sCtx ScanContext) (interface{}, error) {
-	p := sCtx.FilePath()
-	if p == "" {
-		return "", nil
-	}
-	p = filepath.Clean(p)
+	p, _ := originalFilePath(sCtx)
 	return p, nil
 }
 
 func varFileNameFunc(sCtx ScanContext) (interface{}, error) {
-	p, err := varFilePathFunc(sCtx)
-	if err != nil || p == nil || p.(string) == "" {
-		return p, err
+	p, m := originalFilePath(sCtx)
+	if p == "" {
+		return "", nil
 	}
-	base := filepath.Base(p.(string))
+	base := pathBase(p, m)
 	if base == "." {
 		return "", nil
 	}
@@ -411,15 +842,14 @@ func varFileNameFunc(sCtx ScanContext) (interface{}, error) {
 }
 
 func varFileExtensionFunc(sCtx ScanContext) (interface{}, error) {
-	p, err := varFilePathFunc(sCtx)
-	path := p.(string)
-	if err != nil || p == nil || path == "" {
-		return p, err
+	path, m := originalFilePath(sCtx)
+	if path == "" {
+		return "", nil
 	}
 	if strings.HasPrefix(path, ".") && strings.Count(path, ".") == 1 {
 		return "", nil
 	}
-	ext := filepath.Ext(path)
+	ext := filepath.Ext(pathBase(path, m))
 	return strings.TrimPrefix(ext, "."), nil
 }
 
@@ -441,7 +871,7 @@ func intTimeHelper(t time.Time) (interface{}, error) {
 
 func varFileModifiedTimeFunc(sCtx ScanContext) (interface{}, error) {
 	info := sCtx.FileInfo()
-	if info == nil {
+	if info == nil || info.ModTime().IsZero() {
 		return nil, nil
 	}
 	return intTimeHelper(info.ModTime())
@@ -452,7 +882,10 @@ func varFileAccessedTimeFunc(sCtx ScanContext) (interface{}, error) {
 	if info == nil {
 		return nil, nil
 	}
-	ts := times.Get(info)
+	ts := fileTimes(info)
+	if ts == nil || ts.AccessTime().IsZero() {
+		return nil, nil
+	}
 	return intTimeHelper(ts.AccessTime())
 }
 
@@ -461,8 +894,8 @@ func varFileChangedTimeFunc(sCtx ScanContext) (interface{}, error) {
 	if info == nil {
 		return nil, nil
 	}
-	ts := times.Get(info)
-	if ts.HasChangeTime() {
+	ts := fileTimes(info)
+	if ts != nil && ts.HasChangeTime() {
 		return intTimeHelper(ts.ChangeTime())
 	}
 	return nil, nil
@@ -473,13 +906,61 @@ func varFileBirthTimeFunc(sCtx ScanContext) (interface{}, error) {
 	if info == nil {
 		return nil, nil
 	}
-	ts := times.Get(info)
-	if ts.HasBirthTime() {
+	ts := fileTimes(info)
+	if ts != nil && ts.HasBirthTime() {
 		return intTimeHelper(ts.BirthTime())
 	}
 	return nil, nil
 }
 
+func varFilePackageOwnedFunc(sCtx ScanContext) (interface{}, error) {
+	if sCtx.FilePath() == "" {
+		return nil, nil
+	}
+	f, err := filePackage(sCtx)
+	if err != nil {
+		return nil, err
+	}
+	return f != nil, nil
+}
+
+func varFilePackageNameFunc(sCtx ScanContext) (interface{}, error) {
+	f, err := filePackage(sCtx)
+	if f == nil || err != nil {
+		return nil, err
+	}
+	return f.Package, nil
+}
+
+// varFilePackageModifiedFunc reports whether the digest of the file differs from the one recorded by the package
+// manager. The file is only read if the package database verifies the digests, and the file is owned by a package and
+// its digest is recorded. It is read from its real path without updating its access time where it is permitted.
+func varFilePackageModifiedFunc(sCtx ScanContext) (interface{}, error) {
+	if !packageDB(sCtx).VerifyDigests {
+		return nil, nil
+	}
+	f, err := filePackage(sCtx)
+	if f == nil || f.Digest == "" || err != nil {
+		return nil, err
+	}
+	info := sCtx.FileInfo()
+	if info == nil || !isStatInfo(info) || !info.Mode().IsRegular() {
+		return nil, nil
+	}
+	name := realFilePath(sCtx)
+	if name == "" {
+		return nil, nil
+	}
+	digest, err := fileDigest(name, len(f.Digest))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if digest == "" || err != nil {
+		return nil, err
+	}
+	return !strings.EqualFold(digest, f.Digest), nil
+}
+
 func varProcessIdFunc(sCtx ScanContext) (interface{}, error) {
 	return int64(sCtx.Pid()), nil
 }
@@ -519,3 +1000,43 @@ func varProcessCommandLineFunc(sCtx ScanContext) (interface{}, error) {
 	}
 	return proc.CmdlineWithContext(sCtx.Context())
 }
+
+func varRegionPathFunc(sCtx ScanContext) (interface{}, error) {
+	r := memoryRegion(sCtx)
+	if r == nil {
+		return nil, nil
+	}
+	return r.Path, nil
+}
+
+func varRegionPermsFunc(sCtx ScanContext) (interface{}, error) {
+	r := memoryRegion(sCtx)
+	if r == nil {
+		return nil, nil
+	}
+	return r.Perms, nil
+}
+
+func varRegionAnonymousFunc(sCtx ScanContext) (interface{}, error) {
+	r := memoryRegion(sCtx)
+	if r == nil {
+		return nil, nil
+	}
+	return r.Anonymous(), nil
+}
+
+func varRegionBaseFunc(sCtx ScanContext) (interface{}, error) {
+	r := memoryRegion(sCtx)
+	if r == nil {
+		return nil, nil
+	}
+	return int64(r.Base), nil
+}
+
+func varRegionSizeFunc(sCtx ScanContext) (interface{}, error) {
+	r := memoryRegion(sCtx)
+	if r == nil {
+		return nil, nil
+	}
+	return int64(r.Size), nil
+}
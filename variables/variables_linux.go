@@ -0,0 +1,536 @@
+//go:build linux
+// +build linux
+
+package variables
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// fsMagics are the file system types of the magic numbers returned by statfs, used if the mount table cannot be read.
+var fsMagics = map[uint32]string{
+	uint32(unix.BTRFS_SUPER_MAGIC):     "btrfs",
+	uint32(unix.CIFS_SUPER_MAGIC):      "cifs",
+	uint32(unix.EXT4_SUPER_MAGIC):      "ext4",
+	uint32(unix.FUSE_SUPER_MAGIC):      "fuse",
+	uint32(unix.NFS_SUPER_MAGIC):       "nfs",
+	uint32(unix.OVERLAYFS_SUPER_MAGIC): "overlay",
+	uint32(unix.PROC_SUPER_MAGIC):      "proc",
+	uint32(unix.RAMFS_MAGIC):           "ramfs",
+	uint32(unix.SMB2_SUPER_MAGIC):      "smb3",
+	uint32(unix.SYSFS_MAGIC):           "sysfs",
+	uint32(unix.TMPFS_MAGIC):           "tmpfs",
+	uint32(unix.XFS_SUPER_MAGIC):       "xfs",
+}
+
+// DeletedSuffix is appended to the targets of the /proc links of the deleted files by Linux.
+const DeletedSuffix = " (deleted)"
+
+// DeletedLink returns the original path of the file the given /proc link, such as /proc/<pid>/exe or /proc/<pid>/fd/3,
+// points to, if it is a deleted file. The links of the sockets, pipes and anonymous inodes are not paths, and they are
+// ignored.
+func DeletedLink(link string) (string, bool) {
+	target, err := os.Readlink(link)
+	if err != nil || !deletedTarget(link, target) {
+		return "", false
+	}
+	return strings.TrimSuffix(target, DeletedSuffix), true
+}
+
+// deletedTarget reports whether the given target of the given /proc link is of a deleted file. The file must have no
+// links as well, since the live files may be named with DeletedSuffix.
+func deletedTarget(link, target string) bool {
+	if !strings.HasPrefix(target, "/") || !strings.HasSuffix(target, DeletedSuffix) {
+		return false
+	}
+	var st unix.Stat_t
+	return unix.Stat(link, &st) == nil && st.Nlink == 0
+}
+
+func varFileFsTypeFunc(sCtx ScanContext) (interface{}, error) {
+	m, err := fileMount(sCtx)
+	if m == nil || err != nil {
+		return nil, err
+	}
+	return m.FSType, nil
+}
+
+func varFileMountPointFunc(sCtx ScanContext) (interface{}, error) {
+	m, err := fileMount(sCtx)
+	if m == nil || err != nil {
+		return nil, err
+	}
+	return m.Point, nil
+}
+
+func varFileOnTmpfsFunc(sCtx ScanContext) (interface{}, error) {
+	m, err := fileMount(sCtx)
+	if m == nil || err != nil {
+		return nil, err
+	}
+	return m.FSType == "tmpfs", nil
+}
+
+func varFileOnNetworkFsFunc(sCtx ScanContext) (interface{}, error) {
+	m, err := fileMount(sCtx)
+	if m == nil || err != nil {
+		return nil, err
+	}
+	return m.Network(), nil
+}
+
+func varFileMountNoexecFunc(sCtx ScanContext) (interface{}, error) {
+	m, err := fileMount(sCtx)
+	if m == nil || err != nil {
+		return nil, err
+	}
+	return m.HasOption("noexec"), nil
+}
+
+func varProcessCapEffectiveFunc(sCtx ScanContext) (interface{}, error) {
+	return processStatusField(sCtx, "CapEff")
+}
+
+func varProcessCapEffectiveNamesFunc(sCtx ScanContext) (interface{}, error) {
+	return processCapabilityNames(sCtx, "CapEff")
+}
+
+func varProcessCapPermittedFunc(sCtx ScanContext) (interface{}, error) {
+	return processStatusField(sCtx, "CapPrm")
+}
+
+func varProcessCapPermittedNamesFunc(sCtx ScanContext) (interface{}, error) {
+	return processCapabilityNames(sCtx, "CapPrm")
+}
+
+func varProcessNoNewPrivsFunc(sCtx ScanContext) (interface{}, error) {
+	value, err := processStatusField(sCtx, "NoNewPrivs")
+	if value == nil || err != nil {
+		return nil, err
+	}
+	return value == "1", nil
+}
+
+func varProcessSeccompModeFunc(sCtx ScanContext) (interface{}, error) {
+	value, err := processStatusField(sCtx, "Seccomp")
+	if value == nil || err != nil {
+		return nil, err
+	}
+	return strconv.ParseInt(value.(string), 10, 64)
+}
+
+func varProcessSelinuxContextFunc(sCtx ScanContext) (interface{}, error) {
+	return processSecurityLabel(sCtx, "selinux", isSelinuxContext)
+}
+
+func varProcessApparmorProfileFunc(sCtx ScanContext) (interface{}, error) {
+	return processSecurityLabel(sCtx, "apparmor", isApparmorProfile)
+}
+
+// varProcessIsSetuidFunc reports whether the effective or saved user or group id of the process differs from its real
+// one, which is the case for the processes of the setuid and setgid executables.
+func varProcessIsSetuidFunc(sCtx ScanContext) (interface{}, error) {
+	status, err := processStatus(sCtx)
+	if status == nil || err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"Uid", "Gid"} {
+		// The ids are the real, effective, saved and file system ids.
+		ids := strings.Fields(status[key])
+		if len(ids) < 3 {
+			return nil, fmt.Errorf("invalid %s of process status %q", key, status[key])
+		}
+		if ids[1] != ids[0] || ids[2] != ids[0] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func varProcessExeDeletedFunc(sCtx ScanContext) (interface{}, error) {
+	pid := sCtx.Pid()
+	if pid <= 0 {
+		return nil, nil
+	}
+	link := procfs(sCtx).path(pid, "exe")
+	exe, err := os.Readlink(link)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return deletedTarget(link, exe), nil
+}
+
+func varProcessEnvironmentFunc(sCtx ScanContext) (interface{}, error) {
+	p := procfs(sCtx)
+	if !p.Environment {
+		return nil, nil
+	}
+	env, err := processEnviron(sCtx, p)
+	if env == nil || err != nil {
+		return nil, err
+	}
+	included := env[:0]
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		if p.includesKey(key) {
+			included = append(included, kv)
+		}
+	}
+	return strings.Join(included, "\n"), nil
+}
+
+func varProcessLdPreloadFunc(sCtx ScanContext) (interface{}, error) {
+	p := procfs(sCtx)
+	if !p.Environment {
+		return nil, nil
+	}
+	env, err := processEnviron(sCtx, p)
+	if env == nil || err != nil {
+		return nil, err
+	}
+	for _, kv := range env {
+		if value, ok := strings.CutPrefix(kv, "LD_PRELOAD="); ok {
+			return value, nil
+		}
+	}
+	return "", nil
+}
+
+func varProcessLoadedLibrariesFunc(sCtx ScanContext) (interface{}, error) {
+	libs, err := loadedLibraries(sCtx)
+	if libs == nil || err != nil {
+		return nil, err
+	}
+	return strings.Join(libs, "\n"), nil
+}
+
+func varProcessLibraryCountFunc(sCtx ScanContext) (interface{}, error) {
+	libs, err := loadedLibraries(sCtx)
+	if libs == nil || err != nil {
+		return nil, err
+	}
+	return int64(len(libs)), nil
+}
+
+func varProcessListeningPortsFunc(sCtx ScanContext) (interface{}, error) {
+	ps, err := processSocketsOf(sCtx)
+	if ps == nil || err != nil {
+		return nil, err
+	}
+	return strings.Join(ps.listening, "\n"), nil
+}
+
+func varProcessRemoteAddressesFunc(sCtx ScanContext) (interface{}, error) {
+	ps, err := processSocketsOf(sCtx)
+	if ps == nil || err != nil {
+		return nil, err
+	}
+	return strings.Join(ps.remote, "\n"), nil
+}
+
+func varProcessHasRawSocketFunc(sCtx ScanContext) (interface{}, error) {
+	ps, err := processSocketsOf(sCtx)
+	if ps == nil || err != nil {
+		return nil, err
+	}
+	return ps.raw, nil
+}
+
+func varProcessSocketCountFunc(sCtx ScanContext) (interface{}, error) {
+	ps, err := processSocketsOf(sCtx)
+	if ps == nil || err != nil {
+		return nil, err
+	}
+	return int64(ps.count), nil
+}
+
+// processSocketsOf returns the network activity of the process of the given scan context. It returns nil if there is
+// no such process.
+func processSocketsOf(sCtx ScanContext) (*processSockets, error) {
+	pid := sCtx.Pid()
+	if pid <= 0 {
+		return nil, nil
+	}
+	return procfs(sCtx).sockets(pid)
+}
+
+// fileMount returns the mount of the file of the given scan context from the mount table. The files read through
+// procfs, such as the deleted files and the files of the processes, are looked up by their device instead of their real
+// paths. If the mount table cannot be read, such as when procfs is not mounted, the file system type and the noexec
+// option are read using statfs instead. It returns nil if the file is not in the file system.
+func fileMount(sCtx ScanContext) (*Mount, error) {
+	path := attrPath(sCtx)
+	if path == "" {
+		return nil, nil
+	}
+	mounts := mountTable(sCtx)
+	m, err := mounts.Lookup(path)
+	if err == nil && path != sCtx.FilePath() {
+		if major, minor, ok := fileDevice(sCtx.FileInfo()); ok && (m == nil || m.Major != major || m.Minor != minor) {
+			m, err = mounts.lookupDevice(major, minor, sCtx.FilePath())
+			if m == nil && err == nil {
+				err = fs.ErrNotExist
+			}
+		}
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return m, err
+	}
+
+	var st unix.Statfs_t
+	err = unix.Statfs(path, &st)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m = &Mount{FSType: fsMagics[uint32(st.Type)]}
+	if st.Flags&unix.ST_NOEXEC != 0 {
+		m.Options = []string{"noexec"}
+	}
+	return m, nil
+}
+
+// OpenNoAtime opens the given file for reading with O_NOATIME, so reading it does not update its access time, or
+// without it if the caller is not permitted to use it, which requires the ownership of the file or CAP_FOWNER. It
+// reports whether O_NOATIME is used. It is supported on Linux only.
+func OpenNoAtime(name string) (*os.File, bool, error) {
+	fd, err := unix.Open(name, unix.O_RDONLY|unix.O_CLOEXEC|unix.O_NOATIME, 0)
+	if err == nil {
+		return os.NewFile(uintptr(fd), name), true, nil
+	}
+	if !errors.Is(err, unix.EPERM) {
+		return nil, false, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	f, err := os.Open(name)
+	return f, false, err
+}
+
+// fileDevice returns the device numbers of the file system of the given file info.
+func fileDevice(info fs.FileInfo) (major, minor uint32, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return unix.Major(uint64(st.Dev)), unix.Minor(uint64(st.Dev)), true
+}
+
+// processStatus returns the fields of /proc/<pid>/status of the process of the given scan context by their names. It
+// returns nil if there is no such process.
+func processStatus(sCtx ScanContext) (map[string]string, error) {
+	pid := sCtx.Pid()
+	if pid <= 0 {
+		return nil, nil
+	}
+	data, err := os.ReadFile(procfs(sCtx).path(pid, "status"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			status[key] = strings.TrimSpace(value)
+		}
+	}
+	return status, nil
+}
+
+// processStatusField returns the given field of the status of the process of the given scan context. It returns nil
+// if there is no such process or field, such as NoNewPrivs on the older kernels.
+func processStatusField(sCtx ScanContext, key string) (interface{}, error) {
+	status, err := processStatus(sCtx)
+	if status == nil || err != nil {
+		return nil, err
+	}
+	value, ok := status[key]
+	if !ok {
+		return nil, nil
+	}
+	return value, nil
+}
+
+// processCapabilityNames returns the names of the capabilities in the given capability set field of the status of the
+// process of the given scan context.
+func processCapabilityNames(sCtx ScanContext, key string) (interface{}, error) {
+	value, err := processStatusField(sCtx, key)
+	if value == nil || err != nil {
+		return nil, err
+	}
+	set, err := strconv.ParseUint(value.(string), 16, 64)
+	if err != nil {
+		return nil, err
+	}
+	return capabilityNames(set), nil
+}
+
+// processSecurityLabel returns the label of the process of the given scan context set by the given LSM. It is read from
+// /proc/<pid>/attr/<lsm>/current, or from /proc/<pid>/attr/current on the older kernels if the given function reports
+// that the label is set by the LSM. It returns an empty string if the LSM is not enabled.
+func processSecurityLabel(sCtx ScanContext, lsm string, match func(string) bool) (interface{}, error) {
+	pid := sCtx.Pid()
+	if pid <= 0 {
+		return nil, nil
+	}
+	p := procfs(sCtx)
+	data, err := os.ReadFile(p.path(pid, filepath.Join("attr", lsm, "current")))
+	shared := errors.Is(err, fs.ErrNotExist)
+	if shared {
+		data, err = os.ReadFile(p.path(pid, filepath.Join("attr", "current")))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if errors.Is(err, unix.EINVAL) {
+		return "", nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	label := strings.TrimRight(string(data), "\x00\n")
+	if shared && !match(label) {
+		return "", nil
+	}
+	return label, nil
+}
+
+// isApparmorProfile reports whether the given label is an AppArmor profile, such as "unconfined" or
+// "/usr/sbin/cupsd (enforce)".
+func isApparmorProfile(label string) bool {
+	return label == "unconfined" || (strings.HasSuffix(label, ")") && strings.Contains(label, " ("))
+}
+
+// isSelinuxContext reports whether the given label is an SELinux context, such as "system_u:system_r:init_t:s0".
+func isSelinuxContext(label string) bool {
+	return !isApparmorProfile(label) && strings.Count(label, ":") >= 2
+}
+
+// processEnviron returns the environment variables of the process of the given scan context. It returns nil if there
+// is no such process.
+func processEnviron(sCtx ScanContext, p *Procfs) ([]string, error) {
+	pid := sCtx.Pid()
+	if pid <= 0 {
+		return nil, nil
+	}
+	data, err := os.ReadFile(p.path(pid, "environ"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return environ(data), nil
+}
+
+// loadedLibraries returns the paths of the shared objects mapped executable by the process of the given scan context,
+// excluding its executable. It returns nil if there is no such process.
+func loadedLibraries(sCtx ScanContext) ([]string, error) {
+	pid := sCtx.Pid()
+	if pid <= 0 {
+		return nil, nil
+	}
+	p := procfs(sCtx)
+	f, err := os.Open(p.path(pid, "maps"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint errcheck
+
+	regions, err := ParseMemoryMaps(f)
+	if err != nil {
+		return nil, err
+	}
+	exe, _ := os.Readlink(p.path(pid, "exe"))
+
+	libs := []string{}
+	seen := map[string]struct{}{exe: {}}
+	for i := range regions {
+		r := &regions[i]
+		if r.Anonymous() || !r.HasPerms("x") {
+			continue
+		}
+		if _, ok := seen[r.Path]; ok {
+			continue
+		}
+		seen[r.Path] = struct{}{}
+		libs = append(libs, r.Path)
+	}
+	return libs, nil
+}
+
+// fileFlags returns the file attribute flags of the given file, as listed by lsattr.
+func fileFlags(path string) (uint32, error) {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(fd) // nolint errcheck
+	return unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+}
+
+// listXattr returns the names of the extended attributes of the given file.
+func listXattr(path string) ([]string, error) {
+	for {
+		size, err := unix.Listxattr(path, nil)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := unix.Listxattr(path, buf)
+		// The attributes are listed again if they are changed in the meantime.
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		names := []string{}
+		for _, name := range bytes.Split(buf[:n], []byte{0}) {
+			if len(name) > 0 {
+				names = append(names, string(name))
+			}
+		}
+		return names, nil
+	}
+}
+
+// getXattr returns the value of the given extended attribute of the given file, or nil if it is not set.
+func getXattr(path, name string) ([]byte, error) {
+	for {
+		size, err := unix.Getxattr(path, name, nil)
+		if errors.Is(err, unix.ENODATA) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := unix.Getxattr(path, name, buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
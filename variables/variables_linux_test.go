@@ -0,0 +1,394 @@
+//go:build linux
+// +build linux
+
+package variables_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	. "github.com/binalyze/gora/variables"
+)
+
+func TestDeletedVariables(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "deleted"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	var sctx ScanContextImpl
+	sctx.SetPid(os.Getpid())
+	info, err := f.Stat()
+	require.NoError(t, err)
+	sctx.SetFileInfo(info)
+
+	value, err := Valuers[VarFileDeleted].Value(&sctx)
+	require.NoError(t, err)
+	require.Equal(t, false, value)
+
+	require.NoError(t, os.Remove(f.Name()))
+	info, err = f.Stat()
+	require.NoError(t, err)
+	sctx.SetFileInfo(info)
+
+	value, err = Valuers[VarFileDeleted].Value(&sctx)
+	require.NoError(t, err)
+	require.Equal(t, true, value)
+
+	value, err = Valuers[VarProcessExeDeleted].Value(&sctx)
+	require.NoError(t, err)
+	require.Equal(t, false, value)
+}
+
+func TestDeletedLink(t *testing.T) {
+	dir := t.TempDir()
+	// the live files named like the deleted ones are not deleted.
+	live, err := os.Create(filepath.Join(dir, "live"+DeletedSuffix))
+	require.NoError(t, err)
+	defer live.Close()
+	_, ok := DeletedLink(fmt.Sprintf("/proc/self/fd/%d", live.Fd()))
+	require.False(t, ok)
+
+	deleted, err := os.Create(filepath.Join(dir, "deleted"))
+	require.NoError(t, err)
+	defer deleted.Close()
+	link := fmt.Sprintf("/proc/self/fd/%d", deleted.Fd())
+	_, ok = DeletedLink(link)
+	require.False(t, ok)
+
+	require.NoError(t, os.Remove(deleted.Name()))
+	p, ok := DeletedLink(link)
+	require.True(t, ok)
+	require.Equal(t, deleted.Name(), p)
+}
+
+func TestProcfsVariables(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "42")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	require.NoError(t, os.Symlink("/usr/bin/app", filepath.Join(dir, "exe")))
+	environ := "HOME=/root\x00LD_PRELOAD=/tmp/evil.so\x00AWS_SECRET=s3cr3t\x00"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "environ"), []byte(environ), 0o644))
+	maps := `55d0c0a00000-55d0c0a20000 r-xp 00002000 fd:01 1001                       /usr/bin/app
+7f3c1a200000-7f3c1a222000 r--p 00000000 fd:01 2002                       /usr/lib/libc.so.6
+7f3c1a222000-7f3c1a39a000 r-xp 00022000 fd:01 2002                       /usr/lib/libc.so.6
+7f3c1a400000-7f3c1a401000 r-xp 00000000 fd:01 3003                       /tmp/evil.so
+7f3c1a500000-7f3c1a501000 rwxp 00000000 00:00 0 
+7ffd1c3e1000-7ffd1c3e3000 r-xp 00000000 00:00 0                          [vdso]
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "maps"), []byte(maps), 0o644))
+
+	fsys := &Procfs{Root: root}
+	var sctx ScanContextImpl
+	sctx.SetPid(42)
+	sctx.SetProcfs(fsys)
+
+	value := func(v VariableType) interface{} {
+		t.Helper()
+		value, err := Valuers[v].Value(&sctx)
+		require.NoError(t, err)
+		return value
+	}
+
+	// the environment is not read unless it is enabled.
+	require.Nil(t, value(VarProcessEnvironment))
+	require.Nil(t, value(VarProcessLdPreload))
+	require.Equal(t, "/usr/lib/libc.so.6\n/tmp/evil.so", value(VarProcessLoadedLibraries))
+	require.Equal(t, int64(2), value(VarProcessLibraryCount))
+	require.Equal(t, false, value(VarProcessExeDeleted))
+
+	fsys.Environment = true
+	require.Equal(t, "/tmp/evil.so", value(VarProcessLdPreload))
+	require.Equal(t, "HOME=/root\nLD_PRELOAD=/tmp/evil.so\nAWS_SECRET=s3cr3t", value(VarProcessEnvironment))
+	fsys.EnvironmentKeys = []string{"LD_*", "HOME"}
+	require.Equal(t, "HOME=/root\nLD_PRELOAD=/tmp/evil.so", value(VarProcessEnvironment))
+
+	sctx.SetPid(43)
+	require.Nil(t, value(VarProcessLdPreload))
+	require.Nil(t, value(VarProcessLibraryCount))
+}
+
+func TestProcessNetworkVariables(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "42")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "fd"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "net"), 0o755))
+	links := []string{"/dev/null", "pipe:[9]", "socket:[1001]", "socket:[1002]", "socket:[1003]", "socket:[1004]",
+		"socket:[1005]", "socket:[1002]", "socket:[1006]", "socket:[1007]"}
+	for fd, link := range links {
+		require.NoError(t, os.Symlink(link, filepath.Join(dir, "fd", fmt.Sprint(fd))))
+	}
+	header := "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	tables := map[string]string{
+		"tcp": header +
+			"   0: 0200000A:9C40 0500000A:01BB 01 00000000:00000000 00:00000000 00000000  1000        0 1002 1 0 20 4\n",
+		"tcp6": header +
+			"   0: 00000000000000000000000000000000:20FB 00000000000000000000000000000000:0000 0A 00000000:00000000 " +
+			"00:00000000 00000000     0        0 1001 1 0 100 0\n" +
+			"   1: 0000000000000000FFFF00000200000A:9C41 0000000000000000FFFF00000600000A:01BB 01 00000000:00000000 " +
+			"00:00000000 00000000  1000        0 1005 1 0 20 4\n",
+		"udp": header +
+			"  1: 00000000:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 1003 2 0 0\n",
+		"raw": header +
+			"  1: 00000000:00FF 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 1004 2 0 0\n",
+		"unix": "Num       RefCount Protocol Flags    Type St Inode Path\n" +
+			"0000000000000000: 00000002 00000000 00010000 0001 01 1006 /run/app.sock\n" +
+			"0000000000000000: 00000003 00000000 00000000 0001 03 1007\n",
+	}
+	for name, table := range tables {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "net", name), []byte(table), 0o644))
+	}
+
+	fsys := &Procfs{Root: root}
+	var sctx ScanContextImpl
+	sctx.SetPid(42)
+	sctx.SetProcfs(fsys)
+
+	value := func(v VariableType) interface{} {
+		t.Helper()
+		value, err := Valuers[v].Value(&sctx)
+		require.NoError(t, err)
+		return value
+	}
+
+	require.Equal(t, "tcp/8443\nudp/53\nunix//run/app.sock", value(VarProcessListeningPorts))
+	require.Equal(t, "10.0.0.5:443\n10.0.0.6:443", value(VarProcessRemoteAddresses))
+	require.Equal(t, true, value(VarProcessHasRawSocket))
+	require.Equal(t, int64(7), value(VarProcessSocketCount))
+
+	// The socket tables are cached until they are reset.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "net", "tcp"), []byte(header), 0o644))
+	require.Equal(t, "10.0.0.5:443\n10.0.0.6:443", value(VarProcessRemoteAddresses))
+	fsys.ResetNetCache()
+	require.Equal(t, "10.0.0.6:443", value(VarProcessRemoteAddresses))
+
+	// The cached sockets expire after NetMaxAge.
+	fsys.NetMaxAge = time.Nanosecond
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "net", "udp"), []byte(header), 0o644))
+	require.Equal(t, "tcp/8443\nunix//run/app.sock", value(VarProcessListeningPorts))
+
+	sctx.SetPid(43)
+	require.Nil(t, value(VarProcessListeningPorts))
+	require.Nil(t, value(VarProcessSocketCount))
+}
+
+func TestProcessNetworkVariablesSelf(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer udp.Close()
+	unix, err := net.Listen("unix", filepath.Join(t.TempDir(), "sock"))
+	require.NoError(t, err)
+	defer unix.Close()
+
+	var sctx ScanContextImpl
+	sctx.SetPid(os.Getpid())
+	sctx.SetProcfs(&Procfs{})
+
+	value := func(v VariableType) interface{} {
+		t.Helper()
+		value, err := Valuers[v].Value(&sctx)
+		require.NoError(t, err)
+		return value
+	}
+
+	tcpPort := ln.Addr().(*net.TCPAddr).Port
+	udpPort := udp.LocalAddr().(*net.UDPAddr).Port
+	require.Contains(t, value(VarProcessListeningPorts), fmt.Sprintf("tcp/%d", tcpPort))
+	require.Contains(t, value(VarProcessListeningPorts), fmt.Sprintf("udp/%d", udpPort))
+	require.Contains(t, value(VarProcessListeningPorts), "unix/"+unix.Addr().String())
+	require.Contains(t, value(VarProcessRemoteAddresses), ln.Addr().String())
+	require.Equal(t, false, value(VarProcessHasRawSocket))
+	require.GreaterOrEqual(t, value(VarProcessSocketCount), int64(4))
+}
+
+func TestProcessSecurityVariables(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "42")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "attr", "apparmor"), 0o755))
+	status := "Name:\tping\nUid:\t1000\t0\t0\t0\nGid:\t1000\t1000\t1000\t1000\n" +
+		"CapPrm:\t0000000000002000\nCapEff:\t0000020000202001\nNoNewPrivs:\t1\nSeccomp:\t2\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "status"), []byte(status), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "attr", "current"), []byte("system_u:system_r:ping_t:s0\x00"),
+		0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "attr", "apparmor", "current"), []byte("ping (enforce)\n"),
+		0o644))
+
+	var sctx ScanContextImpl
+	sctx.SetPid(42)
+	sctx.SetProcfs(&Procfs{Root: root})
+
+	value := func(v VariableType) interface{} {
+		t.Helper()
+		value, err := Valuers[v].Value(&sctx)
+		require.NoError(t, err)
+		return value
+	}
+
+	require.Equal(t, "0000020000202001", value(VarProcessCapEffective))
+	require.Equal(t, "cap_chown,cap_net_raw,cap_sys_admin,cap_41", value(VarProcessCapEffectiveNames))
+	require.Equal(t, "0000000000002000", value(VarProcessCapPermitted))
+	require.Equal(t, "cap_net_raw", value(VarProcessCapPermittedNames))
+	require.Equal(t, true, value(VarProcessNoNewPrivs))
+	require.Equal(t, int64(2), value(VarProcessSeccompMode))
+	require.Equal(t, true, value(VarProcessIsSetuid))
+	require.Equal(t, "ping (enforce)", value(VarProcessApparmorProfile))
+	require.Equal(t, "system_u:system_r:ping_t:s0", value(VarProcessSelinuxContext))
+
+	// The shared attr/current is used if the LSM has no attr directory, and only if the label is set by the LSM.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "attr", "current"), []byte("unconfined\n"), 0o644))
+	require.Equal(t, "", value(VarProcessSelinuxContext))
+
+	status = "Uid:\t0\t0\t0\t0\nGid:\t0\t0\t0\t0\nCapPrm:\t0\nCapEff:\t0\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "status"), []byte(status), 0o644))
+	require.Equal(t, false, value(VarProcessIsSetuid))
+	require.Equal(t, "", value(VarProcessCapEffectiveNames))
+	require.Nil(t, value(VarProcessNoNewPrivs))
+
+	sctx.SetPid(43)
+	require.Nil(t, value(VarProcessCapEffective))
+	require.Nil(t, value(VarProcessSelinuxContext))
+	require.Nil(t, value(VarProcessIsSetuid))
+}
+
+func TestFileAttributeVariables(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "ping")
+	require.NoError(t, os.WriteFile(name, []byte("ping"), 0o755))
+	info, err := os.Stat(name)
+	require.NoError(t, err)
+
+	var sctx ScanContextImpl
+	sctx.SetFilePath(name)
+	sctx.SetFileInfo(info)
+
+	value := func(v VariableType) interface{} {
+		t.Helper()
+		value, err := Valuers[v].Value(&sctx)
+		require.NoError(t, err)
+		return value
+	}
+
+	xattrs := true
+	if err := unix.Setxattr(name, "user.origin", []byte("test"), 0); errors.Is(err, unix.ENOTSUP) {
+		xattrs = false
+		require.Nil(t, value(VarFileXattrNames))
+	} else {
+		require.NoError(t, err)
+		require.Contains(t, value(VarFileXattrNames), "user.origin")
+	}
+
+	// The revision 2 capabilities with the effective flag, and cap_net_raw and cap_sys_admin permitted.
+	caps := make([]byte, 20)
+	binary.LittleEndian.PutUint32(caps, 0x02000001)
+	binary.LittleEndian.PutUint32(caps[4:], 1<<13|1<<21)
+	if err := unix.Setxattr(name, "security.capability", caps, 0); err == nil {
+		require.Equal(t, "cap_net_raw,cap_sys_admin=ep", value(VarFileCapabilities))
+	} else {
+		t.Logf("file capabilities not tested: %v", err)
+	}
+
+	fd, err := unix.Open(name, unix.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer unix.Close(fd)
+	if err := unix.IoctlSetPointerInt(fd, unix.FS_IOC_SETFLAGS, 0x20); err == nil {
+		defer unix.IoctlSetPointerInt(fd, unix.FS_IOC_SETFLAGS, 0) // nolint errcheck
+		require.Equal(t, true, value(VarFileAppendOnly))
+		require.Equal(t, false, value(VarFileImmutable))
+	} else {
+		t.Logf("file flags not tested: %v", err)
+	}
+
+	// The attributes are read from the real path of the file, such as /proc/<pid>/fd/<fd> of a deleted file.
+	sctx.SetFilePath("/usr/bin/ping")
+	sctx.SetRealPath(fmt.Sprintf("/proc/self/fd/%d", fd))
+	if xattrs {
+		require.Contains(t, value(VarFileXattrNames), "user.origin")
+	}
+	sctx.SetRealPath("")
+	require.Nil(t, value(VarFileXattrNames))
+
+	// The attributes of the files which are not in the file system are not read.
+	sctx.SetFileInfo(&VirtualFileInfo{FileName: "ping", FileSize: 4, FileMode: 0o755})
+	require.Nil(t, value(VarFileXattrNames))
+	require.Nil(t, value(VarFileImmutable))
+}
+
+func TestMountVariables(t *testing.T) {
+	mountInfo := filepath.Join(t.TempDir(), "mountinfo")
+	require.NoError(t, os.WriteFile(mountInfo, []byte("1 0 8:1 / / rw,relatime - ext4 /dev/sda1 rw\n"+
+		"2 1 0:20 / /dev/shm rw,nosuid,nodev,noexec - tmpfs tmpfs rw\n"+
+		"3 1 0:50 / /mnt/share rw - nfs4 server:/export rw\n"), 0o644))
+	info, err := os.Stat(mountInfo)
+	require.NoError(t, err)
+
+	mounts := &MountTable{Path: mountInfo}
+	var sctx ScanContextImpl
+	sctx.SetFileInfo(info)
+	sctx.SetMountTable(mounts)
+
+	value := func(v VariableType) interface{} {
+		t.Helper()
+		value, err := Valuers[v].Value(&sctx)
+		require.NoError(t, err)
+		return value
+	}
+
+	sctx.SetFilePath("/dev/shm/payload")
+	require.Equal(t, "tmpfs", value(VarFileFsType))
+	require.Equal(t, "/dev/shm", value(VarFileMountPoint))
+	require.Equal(t, true, value(VarFileOnTmpfs))
+	require.Equal(t, false, value(VarFileOnNetworkFs))
+	require.Equal(t, true, value(VarFileMountNoexec))
+
+	sctx.SetFilePath("/mnt/share/tool")
+	require.Equal(t, "/mnt/share", value(VarFileMountPoint))
+	require.Equal(t, true, value(VarFileOnNetworkFs))
+	require.Equal(t, false, value(VarFileMountNoexec))
+
+	// The mounts are cached until they are invalidated.
+	require.NoError(t, os.WriteFile(mountInfo, []byte("1 0 8:1 / / rw,relatime - ext4 /dev/sda1 rw\n"), 0o644))
+	require.Equal(t, "nfs4", value(VarFileFsType))
+	mounts.Invalidate()
+	require.Equal(t, "ext4", value(VarFileFsType))
+	require.Equal(t, "/", value(VarFileMountPoint))
+
+	// The files read through procfs are looked up by their device.
+	var st unix.Stat_t
+	require.NoError(t, unix.Stat(mountInfo, &st))
+	require.NoError(t, os.WriteFile(mountInfo, []byte(fmt.Sprintf("1 0 8:1 / / rw,relatime - ext4 /dev/sda1 rw\n"+
+		"2 1 0:4 / /proc rw - proc proc rw\n"+
+		"3 1 %d:%d / /data rw,noexec - xfs /dev/sdb1 rw\n"+
+		"4 1 %[1]d:%d /app /srv/app rw - xfs /dev/sdb1 rw\n", unix.Major(st.Dev), unix.Minor(st.Dev))), 0o644))
+	mounts.Invalidate()
+	sctx.SetRealPath("/proc/self/fd/3")
+	sctx.SetFilePath("/srv/app/tool")
+	require.Equal(t, "/srv/app", value(VarFileMountPoint))
+	require.Equal(t, false, value(VarFileMountNoexec))
+	sctx.SetFilePath("/tmp/tool")
+	require.Equal(t, "/data", value(VarFileMountPoint))
+	require.Equal(t, "xfs", value(VarFileFsType))
+	sctx.SetRealPath(mountInfo)
+
+	// statfs is used if the mount table cannot be read.
+	mounts.Path = filepath.Join(t.TempDir(), "missing")
+	mounts.Invalidate()
+	sctx.SetFilePath(mountInfo)
+	require.Equal(t, "", value(VarFileMountPoint))
+	require.NotNil(t, value(VarFileFsType))
+
+	sctx.SetFileInfo(&VirtualFileInfo{FileName: "payload"})
+	require.Nil(t, value(VarFileFsType))
+}
@@ -0,0 +1,55 @@
+//go:build !linux
+// +build !linux
+
+package variables
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	varFileFsTypeFunc               = noopVarFunc
+	varFileMountPointFunc           = noopVarFunc
+	varFileOnTmpfsFunc              = noopVarFunc
+	varFileOnNetworkFsFunc          = noopVarFunc
+	varFileMountNoexecFunc          = noopVarFunc
+	varProcessCapEffectiveFunc      = noopVarFunc
+	varProcessCapEffectiveNamesFunc = noopVarFunc
+	varProcessCapPermittedFunc      = noopVarFunc
+	varProcessCapPermittedNamesFunc = noopVarFunc
+	varProcessNoNewPrivsFunc        = noopVarFunc
+	varProcessSeccompModeFunc       = noopVarFunc
+	varProcessSelinuxContextFunc    = noopVarFunc
+	varProcessApparmorProfileFunc   = noopVarFunc
+	varProcessIsSetuidFunc          = noopVarFunc
+	varProcessExeDeletedFunc        = noopVarFunc
+	varProcessEnvironmentFunc       = noopVarFunc
+	varProcessLdPreloadFunc         = noopVarFunc
+	varProcessLoadedLibrariesFunc   = noopVarFunc
+	varProcessLibraryCountFunc      = noopVarFunc
+	varProcessListeningPortsFunc    = noopVarFunc
+	varProcessRemoteAddressesFunc   = noopVarFunc
+	varProcessHasRawSocketFunc      = noopVarFunc
+	varProcessSocketCountFunc       = noopVarFunc
+)
+
+// fileFlags, listXattr and getXattr read the file attributes on Linux only.
+
+func fileFlags(string) (uint32, error) {
+	return 0, syscall.ENOTSUP
+}
+
+func listXattr(string) ([]string, error) {
+	return nil, syscall.ENOTSUP
+}
+
+func getXattr(string, string) ([]byte, error) {
+	return nil, syscall.ENOTSUP
+}
+
+// OpenNoAtime opens the given file for reading. O_NOATIME is supported on Linux only, so it reports false.
+func OpenNoAtime(name string) (*os.File, bool, error) {
+	f, err := os.Open(name)
+	return f, false, err
+}
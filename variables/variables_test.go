@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"runtime"
 	"testing"
+	"time"
 
 	. "github.com/binalyze/gora/variables"
 	"github.com/stretchr/testify/mock"
@@ -132,6 +133,16 @@ func TestVariables_ListLength(t *testing.T) {
 	require.Len(t, AllVars, len(Valuers)-1)
 }
 
+func TestVariableType_Values(t *testing.T) {
+	// The values of the variable types must not change, new variables are appended before typeEnd.
+	require.Equal(t, VariableType(1), VarOs)
+	require.Equal(t, VariableType(20), VarFileBirthTime)
+	require.Equal(t, VariableType(21), VarProcessId)
+	require.Equal(t, VariableType(25), VarProcessSessionId)
+	require.Equal(t, VariableType(28), VarProcessCommandLine)
+	require.Equal(t, VariableType(29), VarRegionPath)
+}
+
 func TestVariables_DefineCompilerVariables(t *testing.T) {
 	type args struct {
 		compiler *variableDefinerMock
@@ -380,6 +391,7 @@ func TestVariables_DefineScannerVariables(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			var vr Variables
 			tt.initer(&vr, tt.vars)
+			tt.args.sCtx.On("Context").Return(context.Background()).Maybe()
 			if err := vr.DefineScannerVariables(tt.args.sCtx, tt.args.scanner); (err != nil) != tt.wantErr {
 				t.Errorf("Variables.DefineScannerVariables() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -404,6 +416,9 @@ func TestVariables_DefineScannerVariables_valueError(t *testing.T) {
 	sCtx := new(scanContextMock)
 	errValTest := errors.New("test value error")
 	sCtx.On("HandleValueError").Return(errValTest).Times(1)
+	sCtx.On("Context").Return(context.Background()).Maybe()
+	sCtx.On("FilePath").Return("/bin/app").Maybe()
+	sCtx.On("Pid").Return(0).Maybe()
 
 	scanner := new(variableDefinerMock)
 	scanner.On("DefineVariable", VarFilePath.String(), defaultVarValue(VarFilePath.Meta())).Return(nil).Times(1)
@@ -416,6 +431,42 @@ func TestVariables_DefineScannerVariables_valueError(t *testing.T) {
 	require.Same(t, errValTest, err)
 }
 
+// handlerScanContext is a custom ScanContext collecting the value errors.
+type handlerScanContext struct {
+	ScanContext
+	errs []error
+}
+
+func (c *handlerScanContext) HandleValueError(_ VariableDefiner, _ VariableType, err error) error {
+	c.errs = append(c.errs, err)
+	return nil
+}
+
+func TestVariables_DefineScannerVariables_valueErrorTarget(t *testing.T) {
+	orig := Valuers
+	t.Cleanup(func() {
+		Valuers = orig
+	})
+
+	errTest := errors.New("test error")
+	Valuers[VarProcessName] = ValueFunc(func(_ ScanContext) (interface{}, error) {
+		return nil, errTest
+	})
+
+	var sctx ScanContextImpl
+	sctx.SetFilePath("/bin/app")
+	sctx.SetPid(10)
+	custom := &handlerScanContext{ScanContext: &sctx}
+
+	scanner := new(variableDefinerMock)
+	scanner.On("DefineVariable", VarProcessName.String(), "").Return(nil).Times(1)
+
+	var vr Variables
+	vr.InitVariables([]VariableType{VarProcessName})
+	require.NoError(t, vr.DefineScannerVariables(custom, scanner))
+	require.Equal(t, []error{&ValueError{Variable: VarProcessName, Path: "/bin/app", Pid: 10, Err: errTest}}, custom.errs)
+}
+
 func defaultVarValue(meta MetaType) (defVal interface{}) {
 
 	if meta&MetaString != 0 {
@@ -441,3 +492,74 @@ func TestVariables_Copy(t *testing.T) {
 		t.Errorf("Variables.Copy() = %v, want %v", got, vr1)
 	}
 }
+
+func TestVariables_DefineScannerVariables_valuerTimeout(t *testing.T) {
+	orig := Valuers
+	t.Cleanup(func() {
+		Valuers = orig
+	})
+
+	release := make(chan struct{})
+	t.Cleanup(func() {
+		close(release)
+	})
+	Valuers[VarProcessName] = ValueFunc(func(_ ScanContext) (interface{}, error) {
+		<-release
+		return "late", nil
+	})
+
+	var collector ValueErrorCollector
+	var sctx ScanContextImpl
+	sctx.SetHandleValueError(collector.Handle)
+
+	scanner := new(variableDefinerMock)
+	scanner.On("DefineVariable", VarProcessName.String(), "").Return(nil).Times(1)
+
+	var vr Variables
+	vr.InitVariables([]VariableType{VarProcessName})
+	vr.SetValuerTimeout(10 * time.Millisecond)
+	require.Equal(t, 10*time.Millisecond, vr.Copy().ValuerTimeout())
+
+	require.NoError(t, vr.DefineScannerVariables(&sctx, scanner))
+	scanner.AssertExpectations(t)
+	require.Len(t, collector.Errors(), 1)
+	require.ErrorIs(t, collector.Errors()[0], ErrValuerTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sctx.SetContext(ctx)
+	require.ErrorIs(t, vr.DefineScannerVariables(&sctx, scanner), context.Canceled)
+}
+
+func TestVariables_DefineScannerVariables_valuerTimeoutSnapshot(t *testing.T) {
+	orig := Valuers
+	t.Cleanup(func() {
+		Valuers = orig
+	})
+
+	release := make(chan struct{})
+	seen := make(chan string, 1)
+	Valuers[VarFilePath] = ValueFunc(func(sCtx ScanContext) (interface{}, error) {
+		<-release
+		seen <- sCtx.FilePath()
+		return sCtx.FilePath(), nil
+	})
+
+	var sctx ScanContextImpl
+	sctx.SetFilePath("/first")
+	sctx.SetHandleValueError(IgnoreValueErrors)
+
+	scanner := new(variableDefinerMock)
+	scanner.On("DefineVariable", VarFilePath.String(), "").Return(nil)
+
+	var vr Variables
+	vr.InitVariables([]VariableType{VarFilePath})
+	vr.SetValuerTimeout(10 * time.Millisecond)
+	require.NoError(t, vr.DefineScannerVariables(&sctx, scanner))
+
+	// The abandoned Valuer sees the scan target it was called for after the scan context is reused.
+	sctx.Reset()
+	sctx.SetFilePath("/second")
+	close(release)
+	require.Equal(t, "/first", <-seen)
+}
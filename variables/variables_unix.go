@@ -4,15 +4,133 @@
 package variables
 
 import (
+	"errors"
+	"io/fs"
 	"os/user"
-	"path/filepath"
 	"strings"
+	"syscall"
 
 	"golang.org/x/sys/unix"
 )
 
+// isStatInfo reports whether the given file info is from the file system.
+func isStatInfo(info fs.FileInfo) bool {
+	_, ok := info.Sys().(*syscall.Stat_t)
+	return ok
+}
+
 func varFileHiddenFunc(sCtx ScanContext) (interface{}, error) {
-	return strings.HasPrefix(filepath.Base(sCtx.FilePath()), "."), nil
+	p, m := originalFilePath(sCtx)
+	return strings.HasPrefix(pathBase(p, m), "."), nil
+}
+
+// varFileDeletedFunc reports whether the file has no links, which is the case for the deleted files still open, such
+// as the files opened through /proc/<pid>/fd on Linux.
+func varFileDeletedFunc(sCtx ScanContext) (interface{}, error) {
+	info := sCtx.FileInfo()
+	if info == nil {
+		return nil, nil
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, nil
+	}
+	return st.Nlink == 0, nil
+}
+
+// The file attribute flags returned by FS_IOC_GETFLAGS on Linux.
+const (
+	fsImmutableFl = 0x00000010
+	fsAppendFl    = 0x00000020
+)
+
+func varFileImmutableFunc(sCtx ScanContext) (interface{}, error) {
+	return fileHasFlag(sCtx, fsImmutableFl)
+}
+
+func varFileAppendOnlyFunc(sCtx ScanContext) (interface{}, error) {
+	return fileHasFlag(sCtx, fsAppendFl)
+}
+
+func varFileCapabilitiesFunc(sCtx ScanContext) (interface{}, error) {
+	data, err := fileXattr(sCtx, "security.capability")
+	if data == nil || err != nil {
+		return nil, err
+	}
+	return fileCapabilities(data)
+}
+
+func varFileXattrNamesFunc(sCtx ScanContext) (interface{}, error) {
+	path := attrPath(sCtx)
+	if path == "" {
+		return nil, nil
+	}
+	names, err := listXattr(path)
+	if err != nil {
+		return nil, attrError(err)
+	}
+	return strings.Join(names, "\n"), nil
+}
+
+func varFileSelinuxLabelFunc(sCtx ScanContext) (interface{}, error) {
+	data, err := fileXattr(sCtx, "security.selinux")
+	if data == nil || err != nil {
+		return nil, err
+	}
+	return strings.TrimRight(string(data), "\x00"), nil
+}
+
+// fileHasFlag reports whether the given file attribute flag of the file of the given scan context is set. The flags
+// are only read for the regular files and the directories, since opening the other files may block or have side
+// effects.
+func fileHasFlag(sCtx ScanContext, flag uint32) (interface{}, error) {
+	path := attrPath(sCtx)
+	if path == "" {
+		return nil, nil
+	}
+	if mode := sCtx.FileInfo().Mode(); !mode.IsRegular() && !mode.IsDir() {
+		return nil, nil
+	}
+	flags, err := fileFlags(path)
+	if err != nil {
+		return nil, attrError(err)
+	}
+	return flags&flag != 0, nil
+}
+
+// fileXattr returns the value of the given extended attribute of the file of the given scan context, or nil if it is
+// not set.
+func fileXattr(sCtx ScanContext, name string) ([]byte, error) {
+	path := attrPath(sCtx)
+	if path == "" {
+		return nil, nil
+	}
+	data, err := getXattr(path, name)
+	if err != nil {
+		return nil, attrError(err)
+	}
+	return data, nil
+}
+
+// attrPath returns the path of the file of the given scan context to read its attributes from, which is its real path
+// on the host, or an empty string if the file is not in the file system, such as the archive members.
+func attrPath(sCtx ScanContext) string {
+	info := sCtx.FileInfo()
+	if info == nil || !isStatInfo(info) {
+		return ""
+	}
+	return realFilePath(sCtx)
+}
+
+// attrError returns nil for the errors of the file systems and the platforms not supporting the file attributes, and
+// for the files removed in the meantime, so the attribute variables fall back to their default values.
+func attrError(err error) error {
+	for _, target := range []error{fs.ErrNotExist, unix.ENOTSUP, unix.EOPNOTSUPP, unix.ENOTTY, unix.ENOSYS} {
+		if errors.Is(err, target) {
+			return nil
+		}
+	}
+	return err
 }
 
 var (
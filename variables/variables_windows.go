@@ -10,6 +10,12 @@ import (
 	"golang.org/x/sys/windows"
 )
 
+// isStatInfo reports whether the given file info is from the file system.
+func isStatInfo(info fs.FileInfo) bool {
+	_, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok
+}
+
 func hasFileAttr(info fs.FileInfo, attr uint32) bool {
 	if info == nil {
 		return false
@@ -37,6 +43,15 @@ func varFileEncryptedFunc(sCtx ScanContext) (interface{}, error) {
 	return hasFileAttr(sCtx.FileInfo(), windows.FILE_ATTRIBUTE_ENCRYPTED), nil
 }
 
+var (
+	varFileDeletedFunc      = noopVarFunc
+	varFileImmutableFunc    = noopVarFunc
+	varFileAppendOnlyFunc   = noopVarFunc
+	varFileCapabilitiesFunc = noopVarFunc
+	varFileXattrNamesFunc   = noopVarFunc
+	varFileSelinuxLabelFunc = noopVarFunc
+)
+
 func varProcessSessionIdFunc(sCtx ScanContext) (interface{}, error) {
 	pid := sCtx.Pid()
 	if pid <= 0 {